@@ -0,0 +1,343 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_REVOKE.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_ALTER_DEFAULT_PRIVILEGES.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_SVV_DATASHARE_PRIVILEGES.html
+
+// Implemented by both *sql.DB and *sql.Tx
+type Execer interface {
+	Queryer
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type grantedPrivilege struct {
+	kind      string
+	name      string
+	owner     string
+	acl       string
+	isGrantee bool
+	grantedTo []string //Grantees of the privileges the user granted with a grant option it was given
+}
+
+/*
+Revokes every privilege a user or group has in the database, so that it can be dropped.
+The grants are found in the acl columns of the catalogs rather than by revoking on every schema blindly,
+which also covers functions, procedures, languages, databases and default privileges of other users.
+The privileges a user granted to others also block dropping it, those are revoked as the user first.
+Datashare privileges are not stored in an acl, so they are read from svv_datashare_privileges.
+Stops at the first revoke that fails, as a failed statement aborts the transaction anyway.
+*/
+func revokeAllPrivileges(db Execer, granteeName string, isGroup bool) error {
+
+	var grantee = granteeName
+	var identityType = "user"
+	if isGroup {
+		grantee = "GROUP " + granteeName
+		identityType = "group"
+	}
+
+	privileges, err := getGrantedPrivileges(db, granteeName, isGroup)
+	if err != nil {
+		return fmt.Errorf("Could not find the privileges of %s: %s", grantee, err)
+	}
+
+	var revokeStatements []string
+
+	//Only the grantor can revoke what it granted, a superuser acts as the owner of the object instead
+	for _, privilege := range privileges {
+		for _, entryGrantee := range privilege.grantedTo {
+			revokeStatements = append(revokeStatements,
+				"SET SESSION AUTHORIZATION '"+granteeName+"'",
+				revokePrivilegeStatement(privilege, entryGrantee),
+				"RESET SESSION AUTHORIZATION")
+		}
+	}
+
+	for _, privilege := range privileges {
+		if !privilege.isGrantee && !strings.HasPrefix(privilege.kind, "default ") {
+			continue
+		}
+
+		switch privilege.kind {
+		case "language":
+			revokeStatements = append(revokeStatements, "REVOKE USAGE ON LANGUAGE "+privilege.name+" FROM "+grantee)
+		case "default tables", "default functions", "default procedures":
+			var statement = "ALTER DEFAULT PRIVILEGES FOR USER " + privilege.owner
+			if privilege.name != "" {
+				statement += " IN SCHEMA " + privilege.name
+			}
+			statement += " REVOKE ALL ON " + strings.ToUpper(strings.TrimPrefix(privilege.kind, "default ")) + " FROM "
+
+			//The default privileges a user gives to others also block dropping it
+			if !isGroup && strings.EqualFold(privilege.owner, granteeName) {
+				for _, entry := range strings.Split(privilege.acl, "|") {
+					_, _, entryGrantee := aclEntryGrantee(entry)
+					revokeStatements = append(revokeStatements, statement+entryGrantee)
+				}
+			} else {
+				revokeStatements = append(revokeStatements, statement+grantee)
+			}
+		default:
+			revokeStatements = append(revokeStatements, revokePrivilegeStatement(privilege, grantee))
+		}
+	}
+
+	datashareStatements, err := getDatashareRevokeStatements(db, granteeName, identityType, grantee)
+	if err != nil {
+		return fmt.Errorf("Could not find the datashare privileges of %s: %s", grantee, err)
+	}
+	revokeStatements = append(revokeStatements, datashareStatements...)
+
+	for _, statement := range revokeStatements {
+		log.Print("Revoke statement: " + statement)
+
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("Could not revoke privileges of %s with %s: %s", grantee, statement, err)
+		}
+	}
+
+	return nil
+}
+
+func revokePrivilegeStatement(privilege grantedPrivilege, grantee string) string {
+	if privilege.kind == "language" {
+		return "REVOKE USAGE ON LANGUAGE " + privilege.name + " FROM " + grantee
+	}
+	return "REVOKE ALL ON " + strings.ToUpper(privilege.kind) + " " + privilege.name + " FROM " + grantee
+}
+
+// Returns the objects with an acl that has an entry for the user or group, or an entry granted by the user
+func getGrantedPrivileges(q Queryer, granteeName string, isGroup bool) ([]grantedPrivilege, error) {
+
+	//The position filter only narrows down the acls that are parsed, as the names may be quoted in them
+	var grantedPrivilegesQuery = `SELECT acls.kind, acls.name, acls.owner, acls.acl
+		FROM (
+				SELECT 'schema', QUOTE_IDENT(nc.nspname), '', array_to_string(nc.nspacl, '|')
+				FROM pg_namespace nc
+				WHERE nc.nspacl IS NOT NULL
+				AND   nc.nspname NOT ILIKE 'pg\_temp\_%'
+			UNION ALL
+				-- Tables, views and materialized views are all revoked with REVOKE ON TABLE
+				SELECT 'table', QUOTE_IDENT(nc.nspname) || '.' || QUOTE_IDENT(pgc.relname), '', array_to_string(pgc.relacl, '|')
+				FROM pg_class pgc,
+				     pg_namespace nc
+				WHERE pgc.relnamespace = nc.oid
+				AND   pgc.relkind IN ('r','v')
+				AND   pgc.relacl IS NOT NULL
+				AND   pgc.relname NOT LIKE 'mv\_tbl\_\_%'
+				AND   nc.nspname NOT ILIKE 'pg\_temp\_%'
+			UNION ALL
+				SELECT CASE WHEN pproc.prokind = 'p' THEN 'procedure' ELSE 'function' END,
+				QUOTE_IDENT(nc.nspname) || '.' || QUOTE_IDENT(pproc.proname) || '(' || oidvectortypes(pproc.proargtypes) || ')',
+				'',
+				array_to_string(pproc.proacl, '|')
+				FROM pg_proc_info pproc,
+				     pg_namespace nc
+				WHERE pproc.pronamespace = nc.oid
+				AND   pproc.prokind IN ('f', 'p')
+				AND   pproc.proacl IS NOT NULL
+			UNION ALL
+				SELECT 'database', QUOTE_IDENT(pgd.datname), '', array_to_string(pgd.datacl, '|')
+				FROM pg_database pgd
+				WHERE pgd.datacl IS NOT NULL
+			UNION ALL
+				SELECT 'language', QUOTE_IDENT(pgl.lanname), '', array_to_string(pgl.lanacl, '|')
+				FROM pg_language pgl
+				WHERE pgl.lanacl IS NOT NULL
+			UNION ALL
+				-- Default privileges for objects created by a user, in a schema or in every schema
+				SELECT CASE
+					WHEN pgda.defaclobjtype = 'r' THEN 'default tables'
+					WHEN pgda.defaclobjtype = 'p' THEN 'default procedures'
+					ELSE 'default functions'
+				END,
+				COALESCE(QUOTE_IDENT(nc.nspname), ''),
+				trim(pgu.usename),
+				array_to_string(pgda.defaclacl, '|')
+				FROM pg_default_acl pgda
+				JOIN pg_user pgu ON pgu.usesysid = pgda.defacluser
+				LEFT JOIN pg_namespace nc ON nc.oid = pgda.defaclnamespace
+				WHERE pgda.defaclacl IS NOT NULL
+		)
+		ACLS("kind", "name", "owner", "acl")
+		WHERE position($1 in acls.acl) > 0`
+
+	rows, err := q.Query(grantedPrivilegesQuery, granteeName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var privileges []grantedPrivilege
+
+	for rows.Next() {
+		var privilege grantedPrivilege
+		if err := rows.Scan(&privilege.kind, &privilege.name, &privilege.owner, &privilege.acl); err != nil {
+			return nil, err
+		}
+		var isDefaultsOwner = !isGroup && strings.HasPrefix(privilege.kind, "default ") && strings.EqualFold(privilege.owner, granteeName)
+		privilege.isGrantee = aclHasGrantee(privilege.acl, granteeName, isGroup)
+		if !isGroup && !strings.HasPrefix(privilege.kind, "default ") {
+			privilege.grantedTo = aclGrantedBy(privilege.acl, granteeName)
+		}
+		if isDefaultsOwner || privilege.isGrantee || len(privilege.grantedTo) > 0 {
+			privileges = append(privileges, privilege)
+		}
+	}
+
+	return privileges, rows.Err()
+}
+
+func describeGrantedPrivileges(privileges []grantedPrivilege) string {
+	var descriptions []string
+	for _, privilege := range privileges {
+		switch {
+		case strings.HasPrefix(privilege.kind, "default ") && privilege.name != "":
+			descriptions = append(descriptions, privilege.kind+" of "+privilege.owner+" in schema "+privilege.name)
+		case strings.HasPrefix(privilege.kind, "default "):
+			descriptions = append(descriptions, privilege.kind+" of "+privilege.owner)
+		case privilege.isGrantee:
+			descriptions = append(descriptions, privilege.kind+" "+privilege.name)
+		}
+		for _, grantee := range privilege.grantedTo {
+			descriptions = append(descriptions, privilege.kind+" "+privilege.name+" granted to "+grantee)
+		}
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// Returns the statements revoking ALTER, SHARE and USAGE on datashares from the user or group
+func getDatashareRevokeStatements(q Queryer, granteeName string, identityType string, grantee string) ([]string, error) {
+
+	rows, err := q.Query(`
+			SELECT trim(privilege_type), QUOTE_IDENT(trim(datashare_name))
+			FROM svv_datashare_privileges
+			WHERE identity_type = $1 AND trim(identity_name) = $2`, identityType, granteeName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statements []string
+
+	for rows.Next() {
+		var privilegeType, datashareName string
+		if err := rows.Scan(&privilegeType, &datashareName); err != nil {
+			return nil, err
+		}
+		statements = append(statements, "REVOKE "+strings.ToUpper(privilegeType)+" ON DATASHARE "+datashareName+" FROM "+grantee)
+	}
+
+	return statements, rows.Err()
+}
+
+/*
+Checks whether an acl, as returned by array_to_string(acl, '|'), has an entry for the user or group.
+Entries look like username=arwdRxt/grantor or group groupname=r/grantor, names are double quoted
+when they contain special characters.
+*/
+func aclHasGrantee(acl string, granteeName string, isGroup bool) bool {
+
+	for _, entry := range strings.Split(acl, "|") {
+		name, group, _ := aclEntryGrantee(entry)
+		if group == isGroup && strings.EqualFold(name, granteeName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the grantees, as used in REVOKE, of the entries of an acl that the user granted to someone else
+func aclGrantedBy(acl string, grantorName string) []string {
+
+	var grantees []string
+
+	for _, entry := range strings.Split(acl, "|") {
+		name, isGroup, grantee := aclEntryGrantee(entry)
+		if isGroup || !strings.EqualFold(name, grantorName) {
+			if strings.EqualFold(aclEntryGrantor(entry), grantorName) {
+				grantees = append(grantees, grantee)
+			}
+		}
+	}
+	return grantees
+}
+
+/*
+Returns the unquoted name of the grantee of an acl entry, whether it is a group, and the grantee as used in
+GRANT and REVOKE. The name of PUBLIC is empty.
+*/
+func aclEntryGrantee(entry string) (string, bool, string) {
+
+	var isGroup bool
+	var clause = "GROUP "
+	if strings.HasPrefix(entry, "group ") {
+		isGroup = true
+		entry = strings.TrimPrefix(entry, "group ")
+	} else {
+		clause = ""
+	}
+
+	var (
+		name     strings.Builder
+		inQuotes bool
+	)
+
+	for i := 0; i < len(entry); i++ {
+		c := entry[i]
+		switch {
+		case c == '"' && inQuotes && i+1 < len(entry) && entry[i+1] == '"':
+			name.WriteByte('"')
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == '=' && !inQuotes:
+			return name.String(), isGroup, granteeClause(clause + entry[:i])
+		default:
+			name.WriteByte(c)
+		}
+	}
+
+	return name.String(), isGroup, granteeClause(clause + entry)
+}
+
+// Returns the unquoted name of the grantor of an acl entry, which follows the privileges and a /
+func aclEntryGrantor(entry string) string {
+
+	var inQuotes bool
+
+	for i := 0; i < len(entry); i++ {
+		switch {
+		case entry[i] == '"':
+			inQuotes = !inQuotes
+		case entry[i] == '=' && !inQuotes:
+			privileges := entry[i+1:]
+			if slash := strings.Index(privileges, "/"); slash >= 0 {
+				return unquoteIdentifier(privileges[slash+1:])
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+func unquoteIdentifier(identifier string) string {
+	if len(identifier) >= 2 && strings.HasPrefix(identifier, `"`) && strings.HasSuffix(identifier, `"`) {
+		return strings.Replace(identifier[1:len(identifier)-1], `""`, `"`, -1)
+	}
+	return identifier
+}
+
+func granteeClause(grantee string) string {
+	if grantee == "" {
+		return "PUBLIC"
+	}
+	return grantee
+}
@@ -0,0 +1,72 @@
+package redshift
+
+import (
+	"testing"
+)
+
+func TestAclEntryGrantee(t *testing.T) {
+	var entries = []struct {
+		entry   string
+		name    string
+		isGroup bool
+		grantee string
+	}{
+		{"alice=arwdRxt/admin", "alice", false, "alice"},
+		{"group analysts=r/admin", "analysts", true, "GROUP analysts"},
+		{"=U/admin", "", false, "PUBLIC"},
+		{`"my=user"=r/admin`, "my=user", false, `"my=user"`},
+		{`group "Data ""Team"""=UC/admin`, `Data "Team"`, true, `GROUP "Data ""Team"""`},
+	}
+
+	for _, e := range entries {
+		name, isGroup, grantee := aclEntryGrantee(e.entry)
+		if name != e.name || isGroup != e.isGroup || grantee != e.grantee {
+			t.Fatalf("expected %s to be %s, %t, %s, got %s, %t, %s", e.entry, e.name, e.isGroup, e.grantee, name, isGroup, grantee)
+		}
+	}
+}
+
+func TestAclHasGrantee(t *testing.T) {
+	var acl = "admin=arwdRxt/admin|group analysts=r/admin|analyst_bob=r/admin"
+
+	if !aclHasGrantee(acl, "analysts", true) {
+		t.Fatalf("expected group analysts to be a grantee of %s", acl)
+	}
+	if aclHasGrantee(acl, "analysts", false) {
+		t.Fatalf("expected user analysts not to be a grantee of %s", acl)
+	}
+	if aclHasGrantee(acl, "bob", false) {
+		t.Fatalf("expected user bob not to be a grantee of %s", acl)
+	}
+	if !aclHasGrantee(acl, "analyst_bob", false) {
+		t.Fatalf("expected user analyst_bob to be a grantee of %s", acl)
+	}
+}
+
+func TestAclEntryGrantor(t *testing.T) {
+	var entries = []struct {
+		entry   string
+		grantor string
+	}{
+		{"alice=r/bob", "bob"},
+		{"group analysts=r/admin", "admin"},
+		{`"my=user"=r*/"my/grantor"`, "my/grantor"},
+		{`group g=r/"Data ""Owner"""`, `Data "Owner"`},
+		{"alice=r", ""},
+	}
+
+	for _, e := range entries {
+		if grantor := aclEntryGrantor(e.entry); grantor != e.grantor {
+			t.Fatalf("expected the grantor of %s to be %s, got %s", e.entry, e.grantor, grantor)
+		}
+	}
+}
+
+func TestAclGrantedBy(t *testing.T) {
+	var acl = "bob=arwdRxt/admin|alice=r/bob|group analysts=r/bob|bob=w/bob|carol=r/admin"
+
+	var grantees = aclGrantedBy(acl, "bob")
+	if len(grantees) != 2 || grantees[0] != "alice" || grantees[1] != "GROUP analysts" {
+		t.Fatalf("expected bob to have granted to alice and GROUP analysts in %s, got %v", acl, grantees)
+	}
+}
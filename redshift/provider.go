@@ -46,11 +46,25 @@ func Provider() *schema.Provider {
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"redshift_user":                   redshiftUser(),
-			"redshift_group":                  redshiftGroup(),
-			"redshift_database":               redshiftDatabase(),
-			"redshift_schema":                 redshiftSchema(),
-			"redshift_group_schema_privilege": redshiftSchemaGroupPrivilege(),
+			"redshift_user":                      redshiftUser(),
+			"redshift_user_password":             redshiftUserPassword(),
+			"redshift_group":                     redshiftGroup(),
+			"redshift_group_membership":          redshiftGroupMembership(),
+			"redshift_database":                  redshiftDatabase(),
+			"redshift_schema":                    redshiftSchema(),
+			"redshift_group_schema_privilege":    redshiftSchemaGroupPrivilege(),
+			"redshift_datashare":                 redshiftDatashare(),
+			"redshift_datashare_consumer":        redshiftDatashareConsumer(),
+			"redshift_view":                      redshiftView(),
+			"redshift_materialized_view":         redshiftMaterializedView(),
+			"redshift_table":                     redshiftTable(),
+			"redshift_procedure":                 redshiftProcedure(),
+			"redshift_function":                  redshiftFunction(),
+			"redshift_lambda_function":           redshiftLambdaFunction(),
+			"redshift_masking_policy":            redshiftMaskingPolicy(),
+			"redshift_masking_policy_attachment": redshiftMaskingPolicyAttachment(),
+			"redshift_rls_policy":                redshiftRlsPolicy(),
+			"redshift_rls_policy_attachment":     redshiftRlsPolicyAttachment(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
 			"redshift_schema": dataSourceRedshiftSchema(),
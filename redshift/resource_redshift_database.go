@@ -6,10 +6,13 @@ package redshift
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func redshiftDatabase() *schema.Resource {
@@ -38,6 +41,94 @@ func redshiftDatabase() *schema.Resource {
 				Optional: true,
 				Default:  "UNLIMITED",
 			},
+			"force_destroy": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Terminate all sessions connected to the database before dropping it",
+			},
+			"collate": { //Can only be changed on an empty database, so it is not altered in place
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{"CASE_SENSITIVE", "CASE_INSENSITIVE"}, false),
+				Description:  "Whether string comparison is CASE_SENSITIVE or CASE_INSENSITIVE",
+			},
+			"isolation_level": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice([]string{"SERIALIZABLE", "SNAPSHOT"}, false),
+				Description:  "Isolation level used for queries in the database, SERIALIZABLE or SNAPSHOT",
+			},
+			"datashare": { //Creates a consumer database from a datashare of another cluster
+				Type:          schema.TypeList,
+				Optional:      true,
+				ForceNew:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{"integration"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"share_name": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+						"namespace": {
+							Type:        schema.TypeString,
+							Required:    true,
+							ForceNew:    true,
+							Description: "Namespace guid of the producer cluster",
+						},
+						"account": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Computed:    true,
+							ForceNew:    true,
+							Description: "AWS account id of the producer, if it is in a different account",
+						},
+						"with_permissions": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							ForceNew:    true,
+							Default:     false,
+							Description: "Whether users need object level permissions on the database objects, rather than only usage on the database",
+						},
+					},
+				},
+			},
+			"integration": { //Creates the target database of a zero-ETL integration
+				Type:          schema.TypeList,
+				Optional:      true,
+				ForceNew:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{"datashare"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"integration_id": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+						"source_database": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "Database of the source to replicate, required for Aurora PostgreSQL sources",
+						},
+						"source": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "ARN of the integration source",
+						},
+						"state": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
 		},
 	}
 }
@@ -65,14 +156,29 @@ func resourceRedshiftDatabaseCreate(d *schema.ResourceData, meta interface{}) er
 
 	var createStatement string = "create database " + d.Get("database_name").(string)
 
-	//If no owner is specified it defaults to client user
-	if v, ok := d.GetOk("owner"); ok {
-		var usernames = GetUsersnamesForUsesysid(redshiftClient, []interface{}{v.(int)})
-		createStatement += " OWNER " + usernames[0]
-	}
+	//Owner and connection limit cannot be set when creating from a datashare or an integration
+	if v, ok := d.GetOk("datashare"); ok {
+		createStatement += datashareDatabaseClause(v.([]interface{})[0].(map[string]interface{}))
+	} else if v, ok := d.GetOk("integration"); ok {
+		createStatement += integrationDatabaseClause(v.([]interface{})[0].(map[string]interface{}))
+	} else {
+		//If no owner is specified it defaults to client user
+		if v, ok := d.GetOk("owner"); ok {
+			var usernames = GetUsersnamesForUsesysid(redshiftClient, []interface{}{v.(int)})
+			createStatement += " OWNER " + usernames[0]
+		}
+
+		if v, ok := d.GetOk("connection_limit"); ok {
+			createStatement += " CONNECTION LIMIT " + v.(string)
+		}
+
+		if v, ok := d.GetOk("collate"); ok {
+			createStatement += " COLLATE " + v.(string)
+		}
 
-	if v, ok := d.GetOk("connection_limit"); ok {
-		createStatement += " CONNECTION LIMIT " + v.(string)
+		if v, ok := d.GetOk("isolation_level"); ok {
+			createStatement += " ISOLATION LEVEL " + v.(string)
+		}
 	}
 
 	log.Print("Create database statement: " + createStatement)
@@ -132,9 +238,160 @@ func readRedshiftDatabase(d *schema.ResourceData, db *sql.DB) error {
 		d.Set("connection_limit", nil)
 	}
 
+	if err := readRedshiftDatabaseOptions(d, db, databasename); err != nil {
+		return err
+	}
+
+	if err := readRedshiftDatabaseDatashare(d, db, databasename); err != nil {
+		return err
+	}
+
+	return readRedshiftDatabaseIntegration(d, db, databasename)
+}
+
+func readRedshiftDatabaseOptions(d *schema.ResourceData, db *sql.DB, databasename string) error {
+	var (
+		options        sql.NullString
+		isolationLevel sql.NullString
+	)
+
+	err := db.QueryRow(`
+			SELECT database_options, database_isolation_level
+			FROM svv_redshift_databases
+			WHERE database_name = $1`, databasename).Scan(&options, &isolationLevel)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	//Collation is only listed in the options when the database is case insensitive
+	if strings.Contains(strings.ToUpper(options.String), "CASE_INSENSITIVE") {
+		d.Set("collate", "CASE_INSENSITIVE")
+	} else {
+		d.Set("collate", "CASE_SENSITIVE")
+	}
+
+	//Reported as eg Serializable or Snapshot Isolation
+	if strings.Contains(strings.ToUpper(isolationLevel.String), "SNAPSHOT") {
+		d.Set("isolation_level", "SNAPSHOT")
+	} else {
+		d.Set("isolation_level", "SERIALIZABLE")
+	}
+
+	return nil
+}
+
+func readRedshiftDatabaseDatashare(d *schema.ResourceData, db *sql.DB, databasename string) error {
+	var (
+		shareName         string
+		producerNamespace sql.NullString
+		producerAccount   sql.NullString
+	)
+
+	err := db.QueryRow(`
+			SELECT share_name, producer_namespace, producer_account
+			FROM svv_datashares
+			WHERE share_type = 'INBOUND' AND consumer_database = $1`, databasename).Scan(&shareName, &producerNamespace, &producerAccount)
+
+	switch {
+	case err == sql.ErrNoRows:
+		d.Set("datashare", []interface{}{})
+		return nil
+	case err != nil:
+		log.Print(err)
+		return err
+	}
+
+	//Whether the database was created with permissions is not exposed in the system views
+	var withPermissions bool
+	if v, ok := d.GetOk("datashare"); ok && len(v.([]interface{})) > 0 {
+		withPermissions = v.([]interface{})[0].(map[string]interface{})["with_permissions"].(bool)
+	}
+
+	d.Set("datashare", []interface{}{
+		map[string]interface{}{
+			"share_name":       strings.TrimSpace(shareName),
+			"namespace":        strings.TrimSpace(producerNamespace.String),
+			"account":          strings.TrimSpace(producerAccount.String),
+			"with_permissions": withPermissions,
+		},
+	})
+
+	return nil
+}
+
+func readRedshiftDatabaseIntegration(d *schema.ResourceData, db *sql.DB, databasename string) error {
+	var (
+		integrationId string
+		source        sql.NullString
+		state         sql.NullString
+	)
+
+	err := db.QueryRow(`
+			SELECT integration_id, source, state
+			FROM svv_integration
+			WHERE target_database = $1`, databasename).Scan(&integrationId, &source, &state)
+
+	switch {
+	case err == sql.ErrNoRows:
+		d.Set("integration", []interface{}{})
+		return nil
+	case err != nil:
+		log.Print(err)
+		return err
+	}
+
+	//The source database is only part of the create statement, so it is kept from state
+	var sourceDatabase string
+	if v, ok := d.GetOk("integration"); ok && len(v.([]interface{})) > 0 {
+		sourceDatabase = v.([]interface{})[0].(map[string]interface{})["source_database"].(string)
+	}
+
+	d.Set("integration", []interface{}{
+		map[string]interface{}{
+			"integration_id":  strings.TrimSpace(integrationId),
+			"source_database": sourceDatabase,
+			"source":          strings.TrimSpace(source.String),
+			"state":           strings.TrimSpace(state.String),
+		},
+	})
+
 	return nil
 }
 
+// https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_DATABASE.html, syntax for creating databases from zero-ETL integrations
+func integrationDatabaseClause(integration map[string]interface{}) string {
+
+	var clause = " FROM INTEGRATION '" + integration["integration_id"].(string) + "'"
+
+	if v, ok := integration["source_database"]; ok && v.(string) != "" {
+		clause += " DATABASE " + v.(string)
+	}
+
+	return clause
+}
+
+// https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_DATABASE.html, syntax for creating databases from datashares
+func datashareDatabaseClause(datashare map[string]interface{}) string {
+
+	var clause string
+
+	if datashare["with_permissions"].(bool) {
+		clause += " WITH PERMISSIONS"
+	}
+
+	clause += " FROM DATASHARE " + datashare["share_name"].(string) + " OF"
+
+	if v, ok := datashare["account"]; ok && v.(string) != "" {
+		clause += " ACCOUNT '" + v.(string) + "'"
+	}
+
+	clause += " NAMESPACE '" + datashare["namespace"].(string) + "'"
+
+	return clause
+}
+
 func resourceRedshiftDatabaseUpdate(d *schema.ResourceData, meta interface{}) error {
 
 	redshiftClient := meta.(*Client).db
@@ -169,6 +426,13 @@ func resourceRedshiftDatabaseUpdate(d *schema.ResourceData, meta interface{}) er
 		}
 	}
 
+	//Captured before the read below overwrites the planned values
+	var (
+		isolationLevelChanged = d.HasChange("isolation_level")
+		isolationLevel        = d.Get("isolation_level").(string)
+		databaseName          = d.Get("database_name").(string)
+	)
+
 	err := readRedshiftDatabase(d, redshiftClient)
 
 	if err != nil {
@@ -185,23 +449,141 @@ func resourceRedshiftDatabaseUpdate(d *schema.ResourceData, meta interface{}) er
 		return commitErr
 	}
 
+	//The isolation level cannot be changed inside a transaction block, or while other sessions are connected
+	if isolationLevelChanged {
+		if _, err := redshiftClient.Exec("ALTER DATABASE " + databaseName + " ISOLATION LEVEL " + isolationLevel); err != nil {
+			log.Print(err)
+			return err
+		}
+
+		return readRedshiftDatabase(d, redshiftClient)
+	}
+
 	return nil
 }
 
 func resourceRedshiftDatabaseDelete(d *schema.ResourceData, meta interface{}) error {
 
 	client := meta.(*Client).db
+	databaseName := d.Get("database_name").(string)
+
+	var previousConnectionLimit string
+
+	if d.Get("force_destroy").(bool) {
+		//You cannot drop the database you are connected to, so connect to another one
+		if databaseName == meta.(*Client).config.database {
+			otherClient, err := clientForOtherDatabase(meta.(*Client), databaseName)
+			if err != nil {
+				log.Print(err)
+				return err
+			}
+			defer otherClient.db.Close()
+
+			client = otherClient.db
+		}
+
+		limit, err := terminateDatabaseSessions(client, databaseName)
+		if err != nil {
+			log.Print(err)
+			return err
+		}
+		previousConnectionLimit = limit
+	}
 
-	_, err := client.Exec("drop database " + d.Get("database_name").(string))
+	_, err := client.Exec("drop database " + databaseName)
 
 	if err != nil {
 		log.Print(err)
+		//Otherwise nobody could connect to the database that is left behind
+		if previousConnectionLimit != "" {
+			restoreDatabaseConnectionLimit(client, databaseName, previousConnectionLimit)
+		}
 		return err
 	}
 
 	return nil
 }
 
+/*
+Stops new connections with a connection limit of 0 and terminates the sessions connected to the database.
+Returns the previous connection limit, which is restored when terminating the sessions fails.
+Only the connection running the query is left alone, it is connected to another database when the database
+is the one of the provider. Other sessions of the provider connected to the database are terminated as well,
+since they would make the DROP fail just the same.
+*/
+func terminateDatabaseSessions(client *sql.DB, databaseName string) (string, error) {
+
+	var previousConnectionLimit string
+
+	if err := client.QueryRow("SELECT datconnlimit FROM pg_database_info WHERE datname = $1", databaseName).Scan(&previousConnectionLimit); err != nil {
+		return "", err
+	}
+
+	if _, err := client.Exec("ALTER DATABASE " + databaseName + " CONNECTION LIMIT 0"); err != nil {
+		return "", err
+	}
+
+	rows, err := client.Query(`
+			SELECT pg_terminate_backend(process)
+			FROM stv_sessions
+			WHERE trim(db_name) = $1 AND process <> pg_backend_pid()`, databaseName)
+	if err != nil {
+		restoreDatabaseConnectionLimit(client, databaseName, previousConnectionLimit)
+		return "", err
+	}
+	defer rows.Close()
+
+	var terminated int
+	for rows.Next() {
+		terminated++
+	}
+	if err := rows.Err(); err != nil {
+		restoreDatabaseConnectionLimit(client, databaseName, previousConnectionLimit)
+		return "", err
+	}
+
+	log.Printf("Terminated %d sessions connected to database %s", terminated, databaseName)
+
+	return previousConnectionLimit, nil
+}
+
+func restoreDatabaseConnectionLimit(client *sql.DB, databaseName string, connectionLimit string) {
+	if _, err := client.Exec("ALTER DATABASE " + databaseName + " CONNECTION LIMIT " + connectionLimit); err != nil {
+		log.Printf("Could not restore the connection limit %s of database %s: %v", connectionLimit, databaseName, err)
+	}
+}
+
+// Opens a connection with the provider credentials to any database other than the one given
+func clientForOtherDatabase(c *Client, databaseName string) (*Client, error) {
+
+	var otherDatabase string
+
+	err := c.db.QueryRow(`
+			SELECT datname FROM pg_database
+			WHERE datname <> $1 AND datname NOT IN ('template0', 'template1', 'padb_harvest')
+			ORDER BY datname = 'dev' DESC, datname
+			LIMIT 1`, databaseName).Scan(&otherDatabase)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, fmt.Errorf("Could not find a database other than %s to connect to", databaseName)
+	case err != nil:
+		return nil, err
+	}
+
+	log.Printf("Connecting to database %s to drop database %s", otherDatabase, databaseName)
+
+	return clientForDatabase(c, otherDatabase)
+}
+
+// Returns a client with the same configuration that connects to another database
+func clientForDatabase(c *Client, databaseName string) (*Client, error) {
+
+	config := c.config
+	config.database = databaseName
+
+	return config.Client()
+}
+
 func resourceRedshiftDatabaseImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
 	if err := resourceRedshiftDatabaseRead(d, meta); err != nil {
 		return nil, err
@@ -0,0 +1,441 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_DATASHARE.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_ALTER_DATASHARE.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_DROP_DATASHARE.html
+
+func redshiftDatashare() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftDatashareCreate,
+		Read:   resourceRedshiftDatashareRead,
+		Update: resourceRedshiftDatashareUpdate,
+		Delete: resourceRedshiftDatashareDelete,
+		Exists: resourceRedshiftDatashareExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftDatashareImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"share_name": { //Datashares cannot be renamed
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"publicly_accessible": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether the datashare can be shared to clusters that are publicly accessible",
+			},
+			"schema": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"include_new": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Whether tables, views and functions created in the schema in future are added to the datashare",
+						},
+					},
+				},
+			},
+			"tables": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Tables, views and materialized views in the format schema.table. Their schema must also be in the datashare",
+			},
+			"functions": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Functions in the format schema.function(argument types), as reported by svv_datashare_objects",
+			},
+			"producer_namespace": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"producer_account": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceRedshiftDatashareExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var name string
+
+	err := client.QueryRow("SELECT share_name FROM svv_datashares WHERE share_type = 'OUTBOUND' AND share_id = $1", d.Id()).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftDatashareCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	shareName := d.Get("share_name").(string)
+
+	var createStatement = "CREATE DATASHARE " + shareName + " SET PUBLICACCESSIBLE " + strconv.FormatBool(d.Get("publicly_accessible").(bool))
+
+	log.Print("Create datashare statement: " + createStatement)
+
+	if _, err := tx.Exec(createStatement); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error creating datashare; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not create redshift datashare: %s", err)
+	}
+
+	if err := addDatashareObjects(tx, d, shareName); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error adding datashare objects; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	var shareId string
+	err := tx.QueryRow("SELECT share_id FROM svv_datashares WHERE share_type = 'OUTBOUND' AND share_name = $1", shareName).Scan(&shareId)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting datashare id; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not get redshift datashare id: %s", err)
+	}
+
+	d.SetId(shareId)
+
+	readErr := readRedshiftDatashare(d, tx)
+
+	if readErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading datashare; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(readErr)
+		return readErr
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func resourceRedshiftDatashareRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	err := readRedshiftDatashare(d, tx)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading datashare: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func readRedshiftDatashare(d *schema.ResourceData, tx *sql.Tx) error {
+	var (
+		shareName         string
+		publicAccessible  bool
+		producerNamespace sql.NullString
+		producerAccount   sql.NullString
+		schemas           []map[string]interface{}
+		tables, functions []string
+	)
+
+	err := tx.QueryRow(`
+			SELECT share_name, is_publicaccessible, producer_namespace, producer_account
+			FROM svv_datashares
+			WHERE share_type = 'OUTBOUND' AND share_id = $1`, d.Id()).Scan(&shareName, &publicAccessible, &producerNamespace, &producerAccount)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	rows, err := tx.Query(`
+			SELECT object_type, object_name, coalesce(include_new, false)
+			FROM svv_datashare_objects
+			WHERE share_type = 'OUTBOUND' AND share_name = $1`, shareName)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			objectType string
+			objectName string
+			includeNew bool
+		)
+		if err := rows.Scan(&objectType, &objectName, &includeNew); err != nil {
+			return err
+		}
+
+		switch strings.TrimSpace(strings.ToLower(objectType)) {
+		case "schema":
+			schemas = append(schemas, map[string]interface{}{
+				"name":        strings.TrimSpace(objectName),
+				"include_new": includeNew,
+			})
+		case "function":
+			functions = append(functions, strings.TrimSpace(objectName))
+		default:
+			//Tables, views, late binding views and materialized views are all added with ADD TABLE
+			tables = append(tables, strings.TrimSpace(objectName))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	//Redshift adds the objects created in schemas with include_new to the datashare by itself,
+	//so they are only kept when they are configured, otherwise they would be removed on the next apply
+	var includeNewSchemas = make(map[string]bool)
+	for _, s := range schemas {
+		if s["include_new"].(bool) {
+			includeNewSchemas[s["name"].(string)] = true
+		}
+	}
+	tables = withoutIncludedNewObjects(tables, includeNewSchemas, d.Get("tables").(*schema.Set))
+	functions = withoutIncludedNewObjects(functions, includeNewSchemas, d.Get("functions").(*schema.Set))
+
+	d.Set("share_name", strings.TrimSpace(shareName))
+	d.Set("publicly_accessible", publicAccessible)
+	d.Set("producer_namespace", producerNamespace.String)
+	d.Set("producer_account", producerAccount.String)
+	d.Set("schema", schemas)
+	d.Set("tables", tables)
+	d.Set("functions", functions)
+
+	return nil
+}
+
+func resourceRedshiftDatashareUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	shareName := d.Get("share_name").(string)
+
+	if err := updateDatashare(tx, d, shareName); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error updating datashare: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	err := readRedshiftDatashare(d, tx)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading datashare: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func updateDatashare(tx *sql.Tx, d *schema.ResourceData, shareName string) error {
+
+	if d.HasChange("publicly_accessible") {
+		if _, err := tx.Exec("ALTER DATASHARE " + shareName + " SET PUBLICACCESSIBLE " + strconv.FormatBool(d.Get("publicly_accessible").(bool))); err != nil {
+			return err
+		}
+	}
+
+	oldSchemas, newSchemas := d.GetChange("schema")
+	oldTables, newTables := d.GetChange("tables")
+	oldFunctions, newFunctions := d.GetChange("functions")
+
+	//Objects have to be removed before the schema that contains them
+	for _, v := range oldTables.(*schema.Set).Difference(newTables.(*schema.Set)).List() {
+		if _, err := tx.Exec("ALTER DATASHARE " + shareName + " REMOVE TABLE " + v.(string)); err != nil {
+			return err
+		}
+	}
+	for _, v := range oldFunctions.(*schema.Set).Difference(newFunctions.(*schema.Set)).List() {
+		if _, err := tx.Exec("ALTER DATASHARE " + shareName + " REMOVE FUNCTION " + v.(string)); err != nil {
+			return err
+		}
+	}
+
+	oldIncludeNew := datashareSchemasByName(oldSchemas.(*schema.Set))
+	newIncludeNew := datashareSchemasByName(newSchemas.(*schema.Set))
+
+	for name := range oldIncludeNew {
+		if _, ok := newIncludeNew[name]; !ok {
+			if _, err := tx.Exec("ALTER DATASHARE " + shareName + " REMOVE SCHEMA " + name); err != nil {
+				return err
+			}
+		}
+	}
+	for name, includeNew := range newIncludeNew {
+		previous, existed := oldIncludeNew[name]
+		if !existed {
+			if _, err := tx.Exec("ALTER DATASHARE " + shareName + " ADD SCHEMA " + name); err != nil {
+				return err
+			}
+		}
+		if !existed || previous != includeNew {
+			if err := setDatashareIncludeNew(tx, shareName, name, includeNew); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, v := range newTables.(*schema.Set).Difference(oldTables.(*schema.Set)).List() {
+		if _, err := tx.Exec("ALTER DATASHARE " + shareName + " ADD TABLE " + v.(string)); err != nil {
+			return err
+		}
+	}
+	for _, v := range newFunctions.(*schema.Set).Difference(oldFunctions.(*schema.Set)).List() {
+		if _, err := tx.Exec("ALTER DATASHARE " + shareName + " ADD FUNCTION " + v.(string)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// On create there is no prior state, so every configured object is added
+func addDatashareObjects(tx *sql.Tx, d *schema.ResourceData, shareName string) error {
+
+	for name, includeNew := range datashareSchemasByName(d.Get("schema").(*schema.Set)) {
+		if _, err := tx.Exec("ALTER DATASHARE " + shareName + " ADD SCHEMA " + name); err != nil {
+			return err
+		}
+		if includeNew {
+			if err := setDatashareIncludeNew(tx, shareName, name, includeNew); err != nil {
+				return err
+			}
+		}
+	}
+	for _, v := range d.Get("tables").(*schema.Set).List() {
+		if _, err := tx.Exec("ALTER DATASHARE " + shareName + " ADD TABLE " + v.(string)); err != nil {
+			return err
+		}
+	}
+	for _, v := range d.Get("functions").(*schema.Set).List() {
+		if _, err := tx.Exec("ALTER DATASHARE " + shareName + " ADD FUNCTION " + v.(string)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setDatashareIncludeNew(tx *sql.Tx, shareName string, schemaName string, includeNew bool) error {
+	_, err := tx.Exec("ALTER DATASHARE " + shareName + " SET INCLUDENEW " + strconv.FormatBool(includeNew) + " FOR SCHEMA " + schemaName)
+	return err
+}
+
+// Returns the objects that are configured or not in a schema with include_new, names are in the format schema.object
+func withoutIncludedNewObjects(objects []string, includeNewSchemas map[string]bool, configured *schema.Set) []string {
+	var kept []string
+
+	for _, object := range objects {
+		if includeNewSchemas[strings.SplitN(object, ".", 2)[0]] && !configured.Contains(object) {
+			continue
+		}
+		kept = append(kept, object)
+	}
+
+	return kept
+}
+
+func datashareSchemasByName(schemas *schema.Set) map[string]bool {
+	var byName = make(map[string]bool)
+
+	for _, v := range schemas.List() {
+		m := v.(map[string]interface{})
+		byName[m["name"].(string)] = m["include_new"].(bool)
+	}
+
+	return byName
+}
+
+func resourceRedshiftDatashareDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	_, err := client.Exec("DROP DATASHARE " + d.Get("share_name").(string))
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftDatashareImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if err := resourceRedshiftDatashareRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,198 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_GRANT.html (datashare usage permissions)
+//https://docs.aws.amazon.com/redshift/latest/dg/r_SVV_DATASHARE_CONSUMERS.html
+
+/*
+Id is share_name:namespace:<guid>, share_name:account:<account id> or share_name:account:<account id>:data_catalog
+for grants via the data catalog, which is also the format used for import.
+svv_datashare_consumers does not show whether an account was granted usage via the data catalog, so it is kept in the id.
+*/
+func redshiftDatashareConsumer() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftDatashareConsumerCreate,
+		Read:   resourceRedshiftDatashareConsumerRead,
+		Delete: resourceRedshiftDatashareConsumerDelete,
+		Exists: resourceRedshiftDatashareConsumerExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftDatashareConsumerImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"share_name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"namespace": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"namespace", "account"},
+				Description:  "Namespace guid of the consumer cluster",
+			},
+			"account": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"namespace", "account"},
+				Description:  "AWS account id of the consumer",
+			},
+			"via_data_catalog": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				ForceNew:      true,
+				Default:       false,
+				ConflictsWith: []string{"namespace"},
+				Description:   "Grant usage to the account through the AWS Glue Data Catalog",
+			},
+		},
+	}
+}
+
+func resourceRedshiftDatashareConsumerExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var shareName string
+
+	err := queryDatashareConsumer(client, d).Scan(&shareName)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftDatashareConsumerCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	consumerType, consumer, err := datashareConsumer(d)
+	if err != nil {
+		return err
+	}
+
+	var grantStatement = "GRANT USAGE ON DATASHARE " + d.Get("share_name").(string) + " TO " + strings.ToUpper(consumerType) + " '" + consumer + "'"
+
+	if d.Get("via_data_catalog").(bool) {
+		grantStatement += " VIA DATA CATALOG"
+	}
+
+	log.Print("Grant datashare usage statement: " + grantStatement)
+
+	if _, err := redshiftClient.Exec(grantStatement); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	var id = d.Get("share_name").(string) + ":" + consumerType + ":" + consumer
+	if d.Get("via_data_catalog").(bool) {
+		id += ":data_catalog"
+	}
+	d.SetId(id)
+
+	return readRedshiftDatashareConsumer(d, redshiftClient)
+}
+
+func resourceRedshiftDatashareConsumerRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	return readRedshiftDatashareConsumer(d, redshiftClient)
+}
+
+func readRedshiftDatashareConsumer(d *schema.ResourceData, q Queryer) error {
+
+	var shareName string
+
+	err := queryDatashareConsumer(q, d).Scan(&shareName)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("share_name", strings.TrimSpace(shareName))
+	d.Set("via_data_catalog", strings.HasSuffix(d.Id(), ":data_catalog"))
+
+	return nil
+}
+
+func queryDatashareConsumer(q Queryer, d *schema.ResourceData) *sql.Row {
+
+	consumerType, consumer, _ := datashareConsumer(d)
+
+	if consumerType == "namespace" {
+		return q.QueryRow(`
+			SELECT share_name FROM svv_datashare_consumers
+			WHERE trim(share_name) = $1 AND trim(consumer_namespace) = $2`, d.Get("share_name").(string), consumer)
+	}
+
+	//Account grants are not tied to a namespace
+	return q.QueryRow(`
+			SELECT share_name FROM svv_datashare_consumers
+			WHERE trim(share_name) = $1 AND trim(consumer_account) = $2 AND coalesce(trim(consumer_namespace), '') = ''`, d.Get("share_name").(string), consumer)
+}
+
+func resourceRedshiftDatashareConsumerDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	consumerType, consumer, err := datashareConsumer(d)
+	if err != nil {
+		return err
+	}
+
+	var revokeStatement = "REVOKE USAGE ON DATASHARE " + d.Get("share_name").(string) + " FROM " + strings.ToUpper(consumerType) + " '" + consumer + "'"
+
+	if d.Get("via_data_catalog").(bool) {
+		revokeStatement += " VIA DATA CATALOG"
+	}
+
+	if _, err := client.Exec(revokeStatement); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftDatashareConsumerImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+
+	parts := strings.Split(d.Id(), ":")
+	var validLength = len(parts) == 3 || (len(parts) == 4 && parts[1] == "account" && parts[3] == "data_catalog")
+	if !validLength || (parts[1] != "namespace" && parts[1] != "account") {
+		return nil, fmt.Errorf("Unexpected import id %s, expected share_name:namespace:<guid>, share_name:account:<account id> or share_name:account:<account id>:data_catalog", d.Id())
+	}
+
+	d.Set("share_name", parts[0])
+	d.Set(parts[1], parts[2])
+
+	if err := resourceRedshiftDatashareConsumerRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+// Returns whether usage is granted to a namespace or an account, and the namespace guid or account id
+func datashareConsumer(d *schema.ResourceData) (string, string, error) {
+	if v, ok := d.GetOk("namespace"); ok {
+		return "namespace", v.(string), nil
+	}
+	if v, ok := d.GetOk("account"); ok {
+		return "account", v.(string), nil
+	}
+	return "", "", NewError("Either namespace or account has to be provided")
+}
@@ -0,0 +1,22 @@
+package redshift
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestWithoutIncludedNewObjects(t *testing.T) {
+	var (
+		objects           = []string{"curated.orders", "curated.customers", "public.events"}
+		includeNewSchemas = map[string]bool{"curated": true}
+		configured        = schema.NewSet(schema.HashString, []interface{}{"curated.orders", "public.events"})
+	)
+
+	kept := withoutIncludedNewObjects(objects, includeNewSchemas, configured)
+
+	if !reflect.DeepEqual(kept, []string{"curated.orders", "public.events"}) {
+		t.Fatalf("expected tables added by include_new to be left out, got %v", kept)
+	}
+}
@@ -0,0 +1,389 @@
+package redshift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_FUNCTION.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_ALTER_FUNCTION.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_DROP_FUNCTION.html
+
+/*
+Scalar SQL and Python UDFs. Like procedures, the id is the oid and changing the argument types
+creates a new function. Changing the return type also needs the function to be dropped first.
+*/
+func redshiftFunction() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftFunctionCreate,
+		Read:   resourceRedshiftFunctionRead,
+		Update: resourceRedshiftFunctionUpdate,
+		Delete: resourceRedshiftFunctionDelete,
+		Exists: resourceRedshiftFunctionExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftFunctionImport,
+		},
+		CustomizeDiff: resourceRedshiftFunctionCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"function_name": { //Redshift recommends prefixing UDF names with f_
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"schema_id": {
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			"argument": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": { //SQL UDF arguments are unnamed and referred to as $1, $2 etc, Python UDF arguments need a name
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: true,
+						},
+						"type": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+					},
+				},
+			},
+			"returns": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: suppressEquivalentColumnTypes,
+			},
+			"volatility": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "VOLATILE",
+				ValidateFunc: validation.StringInSlice([]string{"VOLATILE", "STABLE", "IMMUTABLE"}, false),
+			},
+			"language": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice([]string{"sql", "plpythonu"}, false),
+			},
+			"body": {
+				Type:             schema.TypeString,
+				Required:         true,
+				DiffSuppressFunc: suppressEquivalentSqlDiffs,
+				Description:      "A select clause for sql functions or a Python program for plpythonu functions, without the $$ quoting",
+			},
+			"owner": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+				Description: "Defaults to user specified in provider",
+			},
+		},
+	}
+}
+
+func resourceRedshiftFunctionCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	return validateFunctionArguments(d.Get("language").(string), d.Get("argument").([]interface{}))
+}
+
+// Rejects named arguments of sql functions and unnamed arguments of plpythonu functions, which would fail on apply
+func validateFunctionArguments(language string, arguments []interface{}) error {
+	for i, a := range arguments {
+		argument := a.(map[string]interface{})
+		name := argument["name"].(string)
+
+		switch {
+		case language == "sql" && name != "":
+			return fmt.Errorf("Argument %s of a sql function can't have a name, sql functions refer to their arguments as $1, $2 etc", name)
+		case language == "plpythonu" && name == "":
+			return fmt.Errorf("Argument %d of type %s of a plpythonu function needs a name", i+1, argument["type"].(string))
+		}
+	}
+	return nil
+}
+
+func resourceRedshiftFunctionExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var name string
+
+	err := client.QueryRow("SELECT proname FROM pg_proc_info WHERE prokind = 'f' AND prooid = $1", d.Id()).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftFunctionCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	if err := createOrReplaceFunction(tx, d); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error creating function; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not create redshift function: %s", err)
+	}
+
+	signature, signatureErr := routineSignature(tx, d, "function_name")
+	if signatureErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting schema info; unable to rollback: %v", rollbackErr)
+		}
+		return signatureErr
+	}
+
+	oid, oidErr := getRoutineOid(tx, signature)
+	if oidErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting function oid; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not get redshift function id: %s", oidErr)
+	}
+
+	d.SetId(oid)
+
+	if v, ok := d.GetOk("owner"); ok {
+		var usernames = GetUsersnamesForUsesysid(tx, []interface{}{v.(int)})
+		if _, err := tx.Exec("ALTER FUNCTION " + signature + " OWNER TO " + usernames[0]); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error setting function owner; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
+		}
+	}
+
+	readErr := readRedshiftFunction(d, tx)
+
+	if readErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading function; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(readErr)
+		return readErr
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func resourceRedshiftFunctionRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	err := readRedshiftFunction(d, tx)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading function: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func readRedshiftFunction(d *schema.ResourceData, tx *sql.Tx) error {
+	var (
+		functionName string
+		schemaId     int
+		owner        int
+		body         string
+		volatility   string
+		language     string
+		returns      string
+	)
+
+	err := tx.QueryRow(`
+			SELECT trim(p.proname), p.pronamespace, p.proowner, p.prosrc, p.provolatile, trim(l.lanname), format_type(p.prorettype, NULL)
+			FROM pg_proc_info p
+			JOIN pg_language l ON l.oid = p.prolang
+			WHERE p.prokind = 'f' AND p.prooid = $1`, d.Id()).Scan(&functionName, &schemaId, &owner, &body, &volatility, &language, &returns)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("function_name", functionName)
+	d.Set("schema_id", schemaId)
+	d.Set("owner", owner)
+	d.Set("language", language)
+	d.Set("volatility", map[string]string{"v": "VOLATILE", "s": "STABLE", "i": "IMMUTABLE"}[volatility])
+
+	//Functions don't keep the type modifier of their return type, eg the length of varchar, so only that is ignored
+	if returnTypeWithoutModifier(d.Get("returns").(string)) != returnTypeWithoutModifier(returns) {
+		d.Set("returns", returns)
+	}
+
+	//prosrc is the body exactly as it was created, so any difference is a change made outside terraform
+	if normalizeSqlDefinition(body) != normalizeSqlDefinition(d.Get("body").(string)) {
+		d.Set("body", body)
+	}
+
+	return nil
+}
+
+// Normalized type without its arguments, eg character varying for varchar(20)
+func returnTypeWithoutModifier(returnType string) string {
+	normalized := normalizeColumnType(returnType)
+	if i := strings.Index(normalized, "("); i >= 0 {
+		return normalized[:i]
+	}
+	return normalized
+}
+
+func resourceRedshiftFunctionUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	if err := updateRedshiftFunction(tx, d); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error updating function: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	err := readRedshiftFunction(d, tx)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading function: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func updateRedshiftFunction(tx *sql.Tx, d *schema.ResourceData) error {
+
+	if d.HasChange("function_name") {
+		oldName, newName := d.GetChange("function_name")
+
+		schemaName, _, err := GetSchemaInfoForSchemaId(tx, d.Get("schema_id").(int))
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("ALTER FUNCTION " + schemaName + "." + oldName.(string) + routineArgumentTypes(d) + " RENAME TO " + newName.(string)); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("body") || d.HasChange("volatility") || d.HasChange("language") {
+		if err := createOrReplaceFunction(tx, d); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("owner") {
+		signature, err := routineSignature(tx, d, "function_name")
+		if err != nil {
+			return err
+		}
+
+		var usernames = GetUsersnamesForUsesysid(tx, []interface{}{d.Get("owner").(int)})
+		if _, err := tx.Exec("ALTER FUNCTION " + signature + " OWNER TO " + usernames[0]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createOrReplaceFunction(tx *sql.Tx, d *schema.ResourceData) error {
+
+	schemaName, _, err := GetSchemaInfoForSchemaId(tx, d.Get("schema_id").(int))
+	if err != nil {
+		return err
+	}
+
+	var createStatement = "CREATE OR REPLACE FUNCTION " + schemaName + "." + d.Get("function_name").(string) + routineArguments(d) +
+		" RETURNS " + d.Get("returns").(string) + " " + d.Get("volatility").(string) +
+		" AS $$" + d.Get("body").(string) + "$$ LANGUAGE " + d.Get("language").(string)
+
+	log.Print("Create function statement: " + createStatement)
+
+	_, err = tx.Exec(createStatement)
+	return err
+}
+
+func resourceRedshiftFunctionDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	signature, err := routineSignature(client, d, "function_name")
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	if _, err := client.Exec("DROP FUNCTION " + signature); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftFunctionImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if err := importRedshiftRoutineArguments(d, meta.(*Client).db, false); err != nil {
+		return nil, err
+	}
+	if err := resourceRedshiftFunctionRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
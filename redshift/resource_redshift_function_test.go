@@ -0,0 +1,54 @@
+package redshift
+
+import (
+	"testing"
+)
+
+func TestValidateFunctionArguments(t *testing.T) {
+	argument := func(name string, argumentType string) interface{} {
+		return map[string]interface{}{
+			"name": name,
+			"type": argumentType,
+		}
+	}
+
+	var cases = []struct {
+		description string
+		language    string
+		arguments   []interface{}
+		valid       bool
+	}{
+		{"unnamed sql arguments", "sql", []interface{}{argument("", "int"), argument("", "date")}, true},
+		{"named sql argument", "sql", []interface{}{argument("", "int"), argument("day", "date")}, false},
+		{"named python arguments", "plpythonu", []interface{}{argument("a", "float"), argument("b", "float")}, true},
+		{"unnamed python argument", "plpythonu", []interface{}{argument("a", "float"), argument("", "float")}, false},
+		{"no arguments", "sql", []interface{}{}, true},
+	}
+
+	for _, c := range cases {
+		if err := validateFunctionArguments(c.language, c.arguments); (err == nil) != c.valid {
+			t.Fatalf("%s: expected valid to be %v, got %v", c.description, c.valid, err)
+		}
+	}
+}
+
+func TestReturnTypeWithoutModifier(t *testing.T) {
+	var cases = []struct {
+		configured string
+		stored     string
+		equivalent bool
+	}{
+		{"varchar(20)", "character varying", true},
+		{"char(3)", "character", true},
+		{"decimal(12,2)", "numeric", true},
+		{"int", "integer", true},
+		{"int", "bigint", false},
+		{"varchar(20)", "integer", false},
+	}
+
+	for _, c := range cases {
+		if (returnTypeWithoutModifier(c.configured) == returnTypeWithoutModifier(c.stored)) != c.equivalent {
+			t.Fatalf("expected %s and %s to be equivalent: %v", c.configured, c.stored, c.equivalent)
+		}
+	}
+}
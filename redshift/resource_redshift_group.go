@@ -242,34 +242,76 @@ func resourceRedshiftGroupUpdate(d *schema.ResourceData, meta interface{}) error
 
 func resourceRedshiftGroupDelete(d *schema.ResourceData, meta interface{}) error {
 
-	client := meta.(*Client).db
+	client := meta.(*Client)
+	redshiftClient := client.db
+
+	//Groups are shared by all databases, but privileges are not, so they are revoked in every database
+	otherDatabases, databasesErr := getOtherLocalDatabases(redshiftClient, client.config.database)
+	if databasesErr != nil {
+		log.Print(databasesErr)
+		return databasesErr
+	}
+
+	for _, databaseName := range otherDatabases {
+		if err := revokeGroupPrivilegesInDatabase(client, databaseName, d.Get("group_name").(string)); err != nil {
+			log.Print(err)
+			return fmt.Errorf("Could not revoke privileges of group %s in database %s: %s", d.Get("group_name").(string), databaseName, err)
+		}
+	}
+
+	tx, txErr := redshiftClient.Begin()
+
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
 
 	//We need to drop all privileges and default privileges
-	rows, schemasError := client.Query("select nspname from pg_namespace")
-	defer rows.Close()
-	if schemasError != nil {
-		return schemasError
+	if err := revokeAllPrivileges(tx, d.Get("group_name").(string), true); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("revoking privileges failed; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
 	}
 
-	for rows.Next() {
-		var schemaName string
-		err := rows.Scan(&schemaName)
-		if err != nil {
-			//Im not sure how this can happen
-			return err
+	if _, err := tx.Exec("DROP GROUP " + d.Get("group_name").(string)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("drop group failed; unable to rollback: %v", rollbackErr)
 		}
-		client.Exec("REVOKE ALL ON ALL TABLES IN SCHEMA " + schemaName + " FROM GROUP " + d.Get("group_name").(string))
-		client.Exec("ALTER DEFAULT PRIVILEGES IN SCHEMA " + schemaName + " REVOKE ALL ON TABLES FROM GROUP " + d.Get("group_name").(string) + " CASCADE")
+		log.Print(err)
+		return err
 	}
 
-	_, err := client.Exec("DROP GROUP " + d.Get("group_name").(string))
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
 
+	return nil
+}
+
+func revokeGroupPrivilegesInDatabase(c *Client, databaseName string, groupName string) error {
+
+	databaseClient, err := clientForDatabase(c, databaseName)
 	if err != nil {
-		log.Print(err)
 		return err
 	}
+	defer databaseClient.db.Close()
 
-	return nil
+	tx, txErr := databaseClient.db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	if err := revokeAllPrivileges(tx, groupName, true); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("revoking privileges failed; unable to rollback: %v", rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func resourceRedshiftGroupImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
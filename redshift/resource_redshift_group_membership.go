@@ -0,0 +1,363 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// https://docs.aws.amazon.com/redshift/latest/dg/r_ALTER_GROUP.html
+
+/*
+Non-authoritative membership of a group, only the users of this resource are added, read and removed, so
+several modules can each add their own users to the same group.
+Should not be used together with the users of the redshift_group, which are authoritative.
+Id is the grosysid of the group and a hash of the users, eg 100-1a2b3c4d, so that several memberships of
+the same group have their own id. Import with group_id:usesysid,usesysid
+*/
+func redshiftGroupMembership() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftGroupMembershipCreate,
+		Read:   resourceRedshiftGroupMembershipRead,
+		Update: resourceRedshiftGroupMembershipUpdate,
+		Delete: resourceRedshiftGroupMembershipDelete,
+		Exists: resourceRedshiftGroupMembershipExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftGroupMembershipImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"group_id": {
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			//Pass usesysid as username can change
+			"users": {
+				Type:     schema.TypeSet,
+				Required: true,
+				MinItems: 1,
+				Elem:     &schema.Schema{Type: schema.TypeInt},
+			},
+		},
+	}
+}
+
+func resourceRedshiftGroupMembershipExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	//The membership exists as long as the group does, users removed outside terraform are added again
+	_, err := GetGroupNameForGroupId(client, d.Get("group_id").(int))
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftGroupMembershipCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	groupName, err := GetGroupNameForGroupId(tx, d.Get("group_id").(int))
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting group name; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not find group %d: %s", d.Get("group_id").(int), err)
+	}
+
+	if err := alterGroupMembers(tx, groupName, "ADD", d.Get("users").(*schema.Set).List()); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error adding users to group; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	d.SetId(groupMembershipId(d.Get("group_id").(int), d.Get("users").(*schema.Set).List()))
+
+	err = readRedshiftGroupMembership(d, tx)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading group membership: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func resourceRedshiftGroupMembershipRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	return readRedshiftGroupMembership(d, redshiftClient)
+}
+
+func readRedshiftGroupMembership(d *schema.ResourceData, q Queryer) error {
+
+	members, err := getGroupMembers(q, d.Get("group_id").(int))
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	//Other members of the group are managed elsewhere, so only the users of this resource are kept
+	var users = []int{}
+	for _, user := range d.Get("users").(*schema.Set).List() {
+		if contains(members, user) {
+			users = append(users, user.(int))
+		}
+	}
+
+	d.Set("users", users)
+
+	return nil
+}
+
+func resourceRedshiftGroupMembershipUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	groupName, err := GetGroupNameForGroupId(tx, d.Get("group_id").(int))
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting group name; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	if d.HasChange("users") {
+
+		oldUserSet, newUserSet := d.GetChange("users")
+
+		var usersRemoved = difference(oldUserSet.(*schema.Set).List(), newUserSet.(*schema.Set).List())
+		var usersAdded = difference(newUserSet.(*schema.Set).List(), oldUserSet.(*schema.Set).List())
+
+		if err := alterGroupMembers(tx, groupName, "DROP", usersRemoved); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error removing users from group; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
+		}
+		if err := alterGroupMembers(tx, groupName, "ADD", usersAdded); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error adding users to group; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
+		}
+
+		d.SetId(groupMembershipId(d.Get("group_id").(int), newUserSet.(*schema.Set).List()))
+	}
+
+	err = readRedshiftGroupMembership(d, tx)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading group membership: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func resourceRedshiftGroupMembershipDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	groupName, err := GetGroupNameForGroupId(client, d.Get("group_id").(int))
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	members, err := getGroupMembers(client, d.Get("group_id").(int))
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	//Users that already left the group are skipped
+	var users []interface{}
+	for _, user := range d.Get("users").(*schema.Set).List() {
+		if contains(members, user) {
+			users = append(users, user)
+		}
+	}
+
+	if err := alterGroupMembers(client, groupName, "DROP", users); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftGroupMembershipImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+
+	groupId, users, err := parseGroupMembershipImportId(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetId(groupMembershipId(groupId, users))
+	d.Set("group_id", groupId)
+	d.Set("users", users)
+
+	if err := resourceRedshiftGroupMembershipRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+// Parses an import id of the form group_id:usesysid,usesysid
+func parseGroupMembershipImportId(id string) (int, []interface{}, error) {
+
+	parts := strings.SplitN(id, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return 0, nil, fmt.Errorf("Unexpected import id %s, expected group_id:usesysid,usesysid", id)
+	}
+
+	groupId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("Unexpected group id %s in import id %s", parts[0], id)
+	}
+
+	var users []interface{}
+	for _, u := range strings.Split(parts[1], ",") {
+		userId, err := strconv.Atoi(strings.TrimSpace(u))
+		if err != nil {
+			return 0, nil, fmt.Errorf("Unexpected user id %s in import id %s", u, id)
+		}
+		users = append(users, userId)
+	}
+
+	return groupId, users, nil
+}
+
+// Returns group_id-<crc32 of the sorted users>, which doesn't depend on the order of the users
+func groupMembershipId(groupId int, users []interface{}) string {
+
+	var userIds []int
+	for _, user := range users {
+		userIds = append(userIds, user.(int))
+	}
+	sort.Ints(userIds)
+
+	var userList []string
+	for _, userId := range userIds {
+		userList = append(userList, strconv.Itoa(userId))
+	}
+
+	return fmt.Sprintf("%d-%08x", groupId, crc32.ChecksumIEEE([]byte(strings.Join(userList, ","))))
+}
+
+// Runs ALTER GROUP ... ADD USER or DROP USER for the users, if there are any
+func alterGroupMembers(db Execer, groupName string, action string, users []interface{}) error {
+
+	if len(users) == 0 {
+		return nil
+	}
+
+	//Users that don't exist are left out of the usernames, which would break the statement or the next plan
+	var usernames = GetUsersnamesForUsesysid(db, users)
+	if len(usernames) != len(users) {
+		missingUsers, err := getMissingUsers(db, users)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("Could not %s users of group %s: users %v do not exist", strings.ToLower(action), groupName, missingUsers)
+	}
+
+	var alterStatement = "ALTER GROUP " + groupName + " " + action + " USER " + strings.Join(usernames, ", ")
+
+	log.Print("Alter group statement: " + alterStatement)
+
+	_, err := db.Exec(alterStatement)
+	return err
+}
+
+// Returns the usesysid of the users that are not in pg_user_info
+func getMissingUsers(q Queryer, users []interface{}) ([]int, error) {
+
+	var missingUsers []int
+
+	for _, user := range users {
+		var exists bool
+		if err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM pg_user_info WHERE usesysid = $1)", user.(int)).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			missingUsers = append(missingUsers, user.(int))
+		}
+	}
+
+	return missingUsers, nil
+}
+
+// Returns the usesysid of the members of a group from pg_group.grolist
+func getGroupMembers(q Queryer, groupId int) ([]interface{}, error) {
+
+	var users sql.NullString
+
+	if err := q.QueryRow("SELECT grolist FROM pg_group WHERE grosysid = $1", groupId).Scan(&users); err != nil {
+		return nil, err
+	}
+
+	var members = []interface{}{}
+
+	//grolist is an int array such as {100,101}, and null for a group without members
+	if !users.Valid || len(users.String) <= 2 {
+		return members, nil
+	}
+
+	for _, i := range strings.Split(users.String[1:len(users.String)-1], ",") {
+		userId, err := strconv.Atoi(i)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse the members %s of group %d: %s", users.String, groupId, err)
+		}
+		members = append(members, userId)
+	}
+
+	return members, nil
+}
@@ -0,0 +1,47 @@
+package redshift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGroupMembershipImportId(t *testing.T) {
+	var cases = []struct {
+		id      string
+		groupId int
+		users   []interface{}
+		valid   bool
+	}{
+		{"100:101", 100, []interface{}{101}, true},
+		{"100:101,102", 100, []interface{}{101, 102}, true},
+		{"100:101, 102", 100, []interface{}{101, 102}, true},
+		{"100", 0, nil, false},
+		{"100:", 0, nil, false},
+		{"group:101", 0, nil, false},
+		{"100:101,analyst", 0, nil, false},
+	}
+
+	for _, c := range cases {
+		groupId, users, err := parseGroupMembershipImportId(c.id)
+		if (err == nil) != c.valid {
+			t.Fatalf("%s: expected valid to be %v, got %v", c.id, c.valid, err)
+		}
+		if c.valid && (groupId != c.groupId || !reflect.DeepEqual(users, c.users)) {
+			t.Fatalf("%s: expected %d and %v, got %d and %v", c.id, c.groupId, c.users, groupId, users)
+		}
+	}
+}
+
+func TestGroupMembershipId(t *testing.T) {
+	var id = groupMembershipId(100, []interface{}{101, 102})
+
+	if id != groupMembershipId(100, []interface{}{102, 101}) {
+		t.Fatalf("expected the id not to depend on the order of the users")
+	}
+	if id == groupMembershipId(100, []interface{}{101}) {
+		t.Fatalf("expected memberships of the same group with other users to have another id")
+	}
+	if id == groupMembershipId(200, []interface{}{101, 102}) {
+		t.Fatalf("expected memberships of other groups to have another id")
+	}
+}
@@ -0,0 +1,419 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_EXTERNAL_FUNCTION.html
+//https://docs.aws.amazon.com/redshift/latest/dg/udf-creating-a-lambda-sql-udf.html
+
+/*
+Scalar UDFs backed by an AWS Lambda function. The id is the oid, so like the other functions changing
+the argument or return types creates a new function, everything else is changed with CREATE OR REPLACE.
+External functions are the functions in the exfunc language. Redshift doesn't expose the lambda function,
+role, retry timeout or batch settings in a catalog, so those are not read back and only the configured
+values are applied.
+*/
+func redshiftLambdaFunction() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftLambdaFunctionCreate,
+		Read:   resourceRedshiftLambdaFunctionRead,
+		Update: resourceRedshiftLambdaFunctionUpdate,
+		Delete: resourceRedshiftLambdaFunctionDelete,
+		Exists: resourceRedshiftLambdaFunctionExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftLambdaFunctionImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"function_name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"schema_id": {
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			"argument": { //Lambda UDF arguments are unnamed
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"type": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+					},
+				},
+			},
+			"returns": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: suppressEquivalentColumnTypes,
+			},
+			"volatility": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "VOLATILE",
+				ValidateFunc: validation.StringInSlice([]string{"VOLATILE", "STABLE"}, false),
+			},
+			"lambda_function": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Name or arn of the lambda function",
+			},
+			"iam_role": {
+				Type:             schema.TypeString,
+				Required:         true,
+				DiffSuppressFunc: suppressEquivalentIamRoles,
+				Description:      "Arn of a role the cluster can assume to invoke the lambda function, or default to use the default role of the cluster",
+			},
+			"retry_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      20000,
+				ValidateFunc: validation.IntBetween(0, 900000),
+				Description:  "Milliseconds to spend retrying throttled invocations, 0 disables retries",
+			},
+			"max_batch_rows": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"max_batch_size": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringMatch(lambdaBatchSize, "must be a number of KB or MB, eg 512 KB"),
+				Description:  "Maximum payload sent to the lambda function in one invocation, eg 5 MB",
+			},
+			"owner": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+				Description: "Defaults to user specified in provider",
+			},
+		},
+	}
+}
+
+var lambdaBatchSize = regexp.MustCompile(`^[0-9]+ ?(KB|MB)$`)
+
+func resourceRedshiftLambdaFunctionExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var name string
+
+	err := client.QueryRow(`
+			SELECT p.proname
+			FROM pg_proc_info p
+			JOIN pg_language l ON l.oid = p.prolang
+			WHERE p.prokind = 'f' AND l.lanname = 'exfunc' AND p.prooid = $1`, d.Id()).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftLambdaFunctionCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	if err := createOrReplaceLambdaFunction(tx, d); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error creating lambda function; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not create redshift lambda function: %s", err)
+	}
+
+	signature, signatureErr := routineSignature(tx, d, "function_name")
+	if signatureErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting schema info; unable to rollback: %v", rollbackErr)
+		}
+		return signatureErr
+	}
+
+	oid, oidErr := getRoutineOid(tx, signature)
+	if oidErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting lambda function oid; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not get redshift lambda function id: %s", oidErr)
+	}
+
+	d.SetId(oid)
+
+	if v, ok := d.GetOk("owner"); ok {
+		var usernames = GetUsersnamesForUsesysid(tx, []interface{}{v.(int)})
+		if _, err := tx.Exec("ALTER FUNCTION " + signature + " OWNER TO " + usernames[0]); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error setting lambda function owner; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
+		}
+	}
+
+	readErr := readRedshiftLambdaFunction(d, tx)
+
+	if readErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading lambda function; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(readErr)
+		return readErr
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func resourceRedshiftLambdaFunctionRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	err := readRedshiftLambdaFunction(d, tx)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading lambda function: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func readRedshiftLambdaFunction(d *schema.ResourceData, tx *sql.Tx) error {
+	var (
+		functionName string
+		schemaId     int
+		owner        int
+		volatility   string
+		returns      string
+	)
+
+	err := tx.QueryRow(`
+			SELECT trim(p.proname), p.pronamespace, p.proowner, p.provolatile, format_type(p.prorettype, NULL)
+			FROM pg_proc_info p
+			JOIN pg_language l ON l.oid = p.prolang
+			WHERE p.prokind = 'f' AND l.lanname = 'exfunc' AND p.prooid = $1`, d.Id()).Scan(&functionName, &schemaId, &owner, &volatility, &returns)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("function_name", functionName)
+	d.Set("schema_id", schemaId)
+	d.Set("owner", owner)
+	d.Set("volatility", map[string]string{"v": "VOLATILE", "s": "STABLE", "i": "IMMUTABLE"}[volatility])
+
+	//format_type without a type modifier drops eg the length of varchar, so only set it on import
+	if d.Get("returns").(string) == "" {
+		d.Set("returns", returns)
+	}
+
+	return nil
+}
+
+func resourceRedshiftLambdaFunctionUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	if err := updateRedshiftLambdaFunction(tx, d); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error updating lambda function: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	err := readRedshiftLambdaFunction(d, tx)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading lambda function: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func updateRedshiftLambdaFunction(tx *sql.Tx, d *schema.ResourceData) error {
+
+	if d.HasChange("function_name") {
+		oldName, newName := d.GetChange("function_name")
+
+		schemaName, _, err := GetSchemaInfoForSchemaId(tx, d.Get("schema_id").(int))
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("ALTER FUNCTION " + schemaName + "." + oldName.(string) + routineArgumentTypes(d) + " RENAME TO " + newName.(string)); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("volatility") ||
+		d.HasChange("lambda_function") ||
+		d.HasChange("iam_role") ||
+		d.HasChange("retry_timeout") ||
+		d.HasChange("max_batch_rows") ||
+		d.HasChange("max_batch_size") {
+		if err := createOrReplaceLambdaFunction(tx, d); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("owner") {
+		signature, err := routineSignature(tx, d, "function_name")
+		if err != nil {
+			return err
+		}
+
+		var usernames = GetUsersnamesForUsesysid(tx, []interface{}{d.Get("owner").(int)})
+		if _, err := tx.Exec("ALTER FUNCTION " + signature + " OWNER TO " + usernames[0]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createOrReplaceLambdaFunction(tx *sql.Tx, d *schema.ResourceData) error {
+
+	schemaName, _, err := GetSchemaInfoForSchemaId(tx, d.Get("schema_id").(int))
+	if err != nil {
+		return err
+	}
+
+	var createStatement = "CREATE OR REPLACE EXTERNAL FUNCTION " + schemaName + "." + d.Get("function_name").(string) + routineArgumentTypes(d) +
+		" RETURNS " + d.Get("returns").(string) + " " + d.Get("volatility").(string) +
+		" LAMBDA '" + d.Get("lambda_function").(string) + "'"
+
+	if iamRole := d.Get("iam_role").(string); isDefaultIamRole(iamRole) {
+		createStatement += " IAM_ROLE default"
+	} else {
+		createStatement += " IAM_ROLE '" + iamRole + "'"
+	}
+
+	createStatement += " RETRY_TIMEOUT " + strconv.Itoa(d.Get("retry_timeout").(int))
+
+	if v, ok := d.GetOk("max_batch_rows"); ok {
+		createStatement += " MAX_BATCH_ROWS " + strconv.Itoa(v.(int))
+	}
+	if v, ok := d.GetOk("max_batch_size"); ok {
+		createStatement += " MAX_BATCH_SIZE " + v.(string)
+	}
+
+	log.Print("Create lambda function statement: " + createStatement)
+
+	_, err = tx.Exec(createStatement)
+	return err
+}
+
+func isDefaultIamRole(iamRole string) bool {
+	return strings.EqualFold(strings.TrimSpace(iamRole), "default")
+}
+
+// default is a keyword, so any case refers to the default role of the cluster
+func suppressEquivalentIamRoles(k, old, new string, d *schema.ResourceData) bool {
+	return old == new || (isDefaultIamRole(old) && isDefaultIamRole(new))
+}
+
+func resourceRedshiftLambdaFunctionDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	signature, err := routineSignature(client, d, "function_name")
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	if _, err := client.Exec("DROP FUNCTION " + signature); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftLambdaFunctionImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	var argTypes string
+
+	err := meta.(*Client).db.QueryRow(`
+			SELECT oidvectortypes(p.proargtypes)
+			FROM pg_proc_info p
+			JOIN pg_language l ON l.oid = p.prolang
+			WHERE l.lanname = 'exfunc' AND p.prooid = $1`, d.Id()).Scan(&argTypes)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, fmt.Errorf("Function %s is not a lambda function", d.Id())
+	case err != nil:
+		return nil, err
+	}
+
+	var arguments []interface{}
+	for _, t := range splitNonEmpty(argTypes) {
+		arguments = append(arguments, map[string]interface{}{"type": t})
+	}
+	d.Set("argument", arguments)
+
+	if err := resourceRedshiftLambdaFunctionRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,237 @@
+package redshift
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_MASKING_POLICY.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_ALTER_MASKING_POLICY.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_SVV_MASKING_POLICY.html
+
+/*
+Dynamic data masking policies. Policies are not schema objects and have no oid, so the id is the policy name.
+Attaching a policy to a column is done with redshift_masking_policy_attachment.
+
+Redshift rewrites the expressions, so the ones read back from svv_masking_policy are not the same
+as the configured ones. Like the definition of views, the normalized expressions read back are kept
+in database_definition, and the policy is only considered to have drifted when that changes.
+*/
+func redshiftMaskingPolicy() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftMaskingPolicyCreate,
+		Read:   resourceRedshiftMaskingPolicyRead,
+		Update: resourceRedshiftMaskingPolicyUpdate,
+		Delete: resourceRedshiftMaskingPolicyDelete,
+		Exists: resourceRedshiftMaskingPolicyExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftMaskingPolicyImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"policy_name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"input_column": {
+				Type:     schema.TypeList,
+				Required: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+						"type": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ForceNew:         true,
+							DiffSuppressFunc: suppressEquivalentColumnTypes,
+						},
+					},
+				},
+			},
+			"expression": {
+				Type:        schema.TypeList,
+				Required:    true,
+				Description: "One masking expression per output column, eg SHA2(ssn, 256)",
+				Elem: &schema.Schema{
+					Type:             schema.TypeString,
+					DiffSuppressFunc: suppressEquivalentSqlDiffs,
+				},
+			},
+			"database_definition": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Normalized expressions of the policy as read back from svv_masking_policy",
+			},
+		},
+	}
+}
+
+func resourceRedshiftMaskingPolicyExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var name string
+
+	err := client.QueryRow("SELECT policy_name FROM svv_masking_policy WHERE policy_name = $1", d.Id()).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftMaskingPolicyCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	var inputColumns []string
+	for _, c := range d.Get("input_column").([]interface{}) {
+		column := c.(map[string]interface{})
+		inputColumns = append(inputColumns, column["name"].(string)+" "+column["type"].(string))
+	}
+
+	var createStatement = "CREATE MASKING POLICY " + d.Get("policy_name").(string) +
+		" WITH (" + strings.Join(inputColumns, ", ") + ")" +
+		" USING (" + strings.Join(interfacesToStrings(d.Get("expression").([]interface{})), ", ") + ")"
+
+	log.Print("Create masking policy statement: " + createStatement)
+
+	if _, err := redshiftClient.Exec(createStatement); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.SetId(d.Get("policy_name").(string))
+
+	return readRedshiftMaskingPolicy(d, redshiftClient, false)
+}
+
+func resourceRedshiftMaskingPolicyRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	return readRedshiftMaskingPolicy(d, redshiftClient, true)
+}
+
+func readRedshiftMaskingPolicy(d *schema.ResourceData, q Queryer, detectDrift bool) error {
+	var (
+		policyName       string
+		inputColumnsJson string
+		expressionsJson  string
+	)
+
+	err := q.QueryRow(`
+			SELECT trim(policy_name), json_serialize(input_columns), json_serialize(policy_expression)
+			FROM svv_masking_policy
+			WHERE policy_name = $1`, d.Id()).Scan(&policyName, &inputColumnsJson, &expressionsJson)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("policy_name", policyName)
+
+	var inputColumns []struct {
+		Name string `json:"colname"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(inputColumnsJson), &inputColumns); err != nil {
+		return fmt.Errorf("Could not parse input columns of masking policy %s: %s", policyName, err)
+	}
+
+	//The types are stored in their canonical form, so only set them on import
+	if len(d.Get("input_column").([]interface{})) == 0 {
+		var columns []interface{}
+		for _, c := range inputColumns {
+			columns = append(columns, map[string]interface{}{"name": c.Name, "type": c.Type})
+		}
+		d.Set("input_column", columns)
+	}
+
+	var expressions []struct {
+		Expression string `json:"expr"`
+	}
+	if err := json.Unmarshal([]byte(expressionsJson), &expressions); err != nil {
+		return fmt.Errorf("Could not parse expressions of masking policy %s: %s", policyName, err)
+	}
+
+	var (
+		actual     []string
+		normalized []string
+	)
+	for _, e := range expressions {
+		actual = append(actual, e.Expression)
+		normalized = append(normalized, normalizeSqlDefinition(e.Expression))
+	}
+
+	//The configured expressions are only replaced, which shows up as a diff, when the expressions in the
+	//database have changed since they were last applied or read
+	var databaseDefinition = strings.Join(normalized, ", ")
+	var previous = d.Get("database_definition").(string)
+
+	if detectDrift && previous != "" && previous != databaseDefinition {
+		log.Printf("Expressions of masking policy %s have changed outside of terraform", policyName)
+		d.Set("expression", actual)
+	}
+
+	//On import there are no configured expressions yet
+	if len(d.Get("expression").([]interface{})) == 0 {
+		d.Set("expression", actual)
+	}
+
+	d.Set("database_definition", databaseDefinition)
+
+	return nil
+}
+
+func resourceRedshiftMaskingPolicyUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	if d.HasChange("expression") {
+		var alterStatement = "ALTER MASKING POLICY " + d.Get("policy_name").(string) +
+			" USING (" + strings.Join(interfacesToStrings(d.Get("expression").([]interface{})), ", ") + ")"
+
+		if _, err := redshiftClient.Exec(alterStatement); err != nil {
+			log.Print(err)
+			return err
+		}
+	}
+
+	return readRedshiftMaskingPolicy(d, redshiftClient, false)
+}
+
+func resourceRedshiftMaskingPolicyDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	//Fails while the policy is still attached, the attachments are removed first when managed in the same configuration
+	if _, err := client.Exec("DROP MASKING POLICY " + d.Get("policy_name").(string)); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftMaskingPolicyImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if err := resourceRedshiftMaskingPolicyRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
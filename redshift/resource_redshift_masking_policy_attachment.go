@@ -0,0 +1,371 @@
+package redshift
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_ATTACH_MASKING_POLICY.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_DETACH_MASKING_POLICY.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_SVV_ATTACHED_MASKING_POLICY.html
+
+/*
+Id is policy_name:table_id:output_columns:user:<usesysid>, policy_name:table_id:output_columns:role:<role name>
+or policy_name:table_id:output_columns:public, with the output columns separated by commas.
+This is also the format used for import.
+*/
+func redshiftMaskingPolicyAttachment() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftMaskingPolicyAttachmentCreate,
+		Read:   resourceRedshiftMaskingPolicyAttachmentRead,
+		Delete: resourceRedshiftMaskingPolicyAttachmentDelete,
+		Exists: resourceRedshiftMaskingPolicyAttachmentExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftMaskingPolicyAttachmentImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"policy_name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"table_id": {
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			"output_columns": {
+				Type:     schema.TypeList,
+				Required: true,
+				ForceNew: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"input_columns": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Defaults to the output columns",
+			},
+			"user": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"user", "role", "public"},
+			},
+			"role": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"user", "role", "public"},
+			},
+			"public": {
+				Type:         schema.TypeBool,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"user", "role", "public"},
+			},
+			"priority": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     0,
+				Description: "The policy with the highest priority is applied when several policies apply to a column for a user",
+			},
+		},
+	}
+}
+
+func resourceRedshiftMaskingPolicyAttachmentExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	_, _, err := queryMaskingPolicyAttachment(client, d)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftMaskingPolicyAttachmentCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	schemaName, tableName, err := getRelationName(redshiftClient, d.Get("table_id").(int))
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	grantee, err := policyGrantee(redshiftClient, d)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("User %d does not exist", d.Get("user").(int))
+	}
+	if err != nil {
+		return err
+	}
+
+	var attachStatement = "ATTACH MASKING POLICY " + d.Get("policy_name").(string) +
+		" ON " + schemaName + "." + tableName + "(" + strings.Join(interfacesToStrings(d.Get("output_columns").([]interface{})), ", ") + ")"
+
+	if v, ok := d.GetOk("input_columns"); ok {
+		attachStatement += " USING (" + strings.Join(interfacesToStrings(v.([]interface{})), ", ") + ")"
+	}
+
+	attachStatement += " TO " + grantee + " PRIORITY " + strconv.Itoa(d.Get("priority").(int))
+
+	log.Print("Attach masking policy statement: " + attachStatement)
+
+	if _, err := redshiftClient.Exec(attachStatement); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.SetId(maskingPolicyAttachmentId(d))
+
+	return readRedshiftMaskingPolicyAttachment(d, redshiftClient)
+}
+
+func resourceRedshiftMaskingPolicyAttachmentRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	err := readRedshiftMaskingPolicyAttachment(d, redshiftClient)
+	if err == sql.ErrNoRows {
+		//The policy was detached, or the table or user dropped, outside terraform
+		log.Printf("Masking policy attachment %s not found, removing it from the state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	return err
+}
+
+func readRedshiftMaskingPolicyAttachment(d *schema.ResourceData, q Queryer) error {
+
+	priority, inputColumns, err := queryMaskingPolicyAttachment(q, d)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("priority", priority)
+	d.Set("input_columns", inputColumns)
+
+	return nil
+}
+
+/*
+svv_attached_masking_policy has a row per policy, table and grantee, the same policy can be attached to
+other columns of the table for the same grantee so the output columns are compared here.
+Returns the priority and input columns, or sql.ErrNoRows when the policy is not attached.
+*/
+func queryMaskingPolicyAttachment(q Queryer, d *schema.ResourceData) (int, []string, error) {
+
+	schemaName, tableName, err := getRelationName(q, d.Get("table_id").(int))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	granteeType, grantee, err := policyGranteeKind(q, d)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := q.Query(`
+			SELECT priority, json_serialize(input_columns), json_serialize(output_columns)
+			FROM svv_attached_masking_policy
+			WHERE policy_name = $1 AND schema_name = $2 AND table_name = $3 AND grantee_type = $4 AND grantee = $5`,
+		d.Get("policy_name").(string), schemaName, tableName, granteeType, grantee)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var outputColumns = strings.Join(interfacesToStrings(d.Get("output_columns").([]interface{})), ",")
+
+	for rows.Next() {
+		var (
+			priority          int
+			inputColumnsJson  string
+			outputColumnsJson string
+			inputColumns      []string
+			attachedColumns   []string
+		)
+
+		if err := rows.Scan(&priority, &inputColumnsJson, &outputColumnsJson); err != nil {
+			return 0, nil, err
+		}
+		if err := json.Unmarshal([]byte(outputColumnsJson), &attachedColumns); err != nil {
+			return 0, nil, fmt.Errorf("Could not parse output columns of masking policy %s: %s", d.Get("policy_name").(string), err)
+		}
+		if err := json.Unmarshal([]byte(inputColumnsJson), &inputColumns); err != nil {
+			return 0, nil, fmt.Errorf("Could not parse input columns of masking policy %s: %s", d.Get("policy_name").(string), err)
+		}
+
+		if strings.EqualFold(strings.Join(attachedColumns, ","), outputColumns) {
+			return priority, inputColumns, nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	return 0, nil, sql.ErrNoRows
+}
+
+func resourceRedshiftMaskingPolicyAttachmentDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	schemaName, tableName, err := getRelationName(client, d.Get("table_id").(int))
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	grantee, err := policyGrantee(client, d)
+	if err == sql.ErrNoRows {
+		//Policies are detached when the user is dropped
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var detachStatement = "DETACH MASKING POLICY " + d.Get("policy_name").(string) +
+		" ON " + schemaName + "." + tableName + "(" + strings.Join(interfacesToStrings(d.Get("output_columns").([]interface{})), ", ") + ")" +
+		" FROM " + grantee
+
+	if _, err := client.Exec(detachStatement); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftMaskingPolicyAttachmentImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+
+	parts := strings.SplitN(d.Id(), ":", 5)
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("Unexpected import id %s, expected policy_name:table_id:output_columns:user:<usesysid>, policy_name:table_id:output_columns:role:<role name> or policy_name:table_id:output_columns:public", d.Id())
+	}
+
+	tableId, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("Unexpected table id %s in import id %s", parts[1], d.Id())
+	}
+
+	d.Set("policy_name", parts[0])
+	d.Set("table_id", tableId)
+	d.Set("output_columns", strings.Split(parts[2], ","))
+
+	if err := importPolicyGrantee(d, parts[3:]); err != nil {
+		return nil, err
+	}
+
+	if err := resourceRedshiftMaskingPolicyAttachmentRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+func maskingPolicyAttachmentId(d *schema.ResourceData) string {
+
+	return d.Get("policy_name").(string) + ":" + strconv.Itoa(d.Get("table_id").(int)) + ":" +
+		strings.Join(interfacesToStrings(d.Get("output_columns").([]interface{})), ",") + ":" + policyGranteeId(d)
+}
+
+// Returns user:<usesysid>, role:<role name> or public, the last part of the id of masking and rls policy attachments
+func policyGranteeId(d *schema.ResourceData) string {
+	if v, ok := d.GetOk("user"); ok {
+		return "user:" + strconv.Itoa(v.(int))
+	}
+	if v, ok := d.GetOk("role"); ok {
+		return "role:" + v.(string)
+	}
+	return "public"
+}
+
+// Sets the grantee from the parts of an import id in the format returned by policyGranteeId
+func importPolicyGrantee(d *schema.ResourceData, parts []string) error {
+	switch {
+	case len(parts) == 1 && parts[0] == "public":
+		d.Set("public", true)
+	case len(parts) == 2 && parts[0] == "role":
+		d.Set("role", parts[1])
+	case len(parts) == 2 && parts[0] == "user":
+		userId, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return fmt.Errorf("Unexpected user id %s in import id %s", parts[1], d.Id())
+		}
+		d.Set("user", userId)
+	default:
+		return fmt.Errorf("Unexpected grantee in import id %s, expected user:<usesysid>, role:<role name> or public", d.Id())
+	}
+	return nil
+}
+
+// Returns the grantee as used in ATTACH and DETACH of masking and rls policies, or sql.ErrNoRows when the user was dropped
+func policyGrantee(q Queryer, d *schema.ResourceData) (string, error) {
+	if _, ok := d.GetOk("user"); ok {
+		return policyGranteeUsername(q, d)
+	}
+	if v, ok := d.GetOk("role"); ok {
+		return "ROLE " + v.(string), nil
+	}
+	if d.Get("public").(bool) {
+		return "PUBLIC", nil
+	}
+	return "", NewError("One of user, role or public has to be provided")
+}
+
+// Returns the kind of grantee and its name as shown in the system views of attached masking and rls policies,
+// or sql.ErrNoRows when the user was dropped
+func policyGranteeKind(q Queryer, d *schema.ResourceData) (string, string, error) {
+	if _, ok := d.GetOk("user"); ok {
+		username, err := policyGranteeUsername(q, d)
+		return "user", username, err
+	}
+	if v, ok := d.GetOk("role"); ok {
+		return "role", v.(string), nil
+	}
+	return "public", "public", nil
+}
+
+func policyGranteeUsername(q Queryer, d *schema.ResourceData) (string, error) {
+	var usernames = GetUsersnamesForUsesysid(q, []interface{}{d.Get("user").(int)})
+	if len(usernames) == 0 {
+		return "", sql.ErrNoRows
+	}
+	return usernames[0], nil
+}
+
+// Returns the schema and name of a table or view
+func getRelationName(q Queryer, relationId int) (string, string, error) {
+
+	var schemaName, relationName string
+
+	err := q.QueryRow(`
+			SELECT trim(n.nspname), trim(c.relname)
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE c.oid = $1`, relationId).Scan(&schemaName, &relationName)
+	if err != nil {
+		return "", "", err
+	}
+	return schemaName, relationName, nil
+}
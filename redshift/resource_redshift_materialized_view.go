@@ -0,0 +1,517 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/materialized-view-create-sql-command.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_ALTER_MATERIALIZED_VIEW.html
+//https://docs.aws.amazon.com/redshift/latest/dg/materialized-view-drop-sql-command.html
+
+/*
+Like views, the id is the oid in pg_class and the definition read back is compared with the one
+read after the last apply. Changing the query recreates the materialized view. Refreshing is left
+to auto refresh or to whatever schedules REFRESH MATERIALIZED VIEW.
+*/
+func redshiftMaterializedView() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftMaterializedViewCreate,
+		Read:   resourceRedshiftMaterializedViewRead,
+		Update: resourceRedshiftMaterializedViewUpdate,
+		Delete: resourceRedshiftMaterializedViewDelete,
+		Exists: resourceRedshiftMaterializedViewExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftMaterializedViewImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"view_name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"schema_id": {
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			"definition": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: suppressEquivalentSqlDiffs,
+				Description:      "The select query of the materialized view",
+			},
+			"backup": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+				ForceNew: true,
+			},
+			"diststyle": { //Redshift picks one when it isn't set, which is read back
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{"EVEN", "ALL", "KEY", "AUTO"}, false),
+			},
+			"distkey": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+			},
+			"sortkey": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"auto_refresh": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"select_groups": { //Pass grosysid as group names can change
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeInt},
+				Description: "Groups that are granted select on the materialized view. Grants are applied again when it is recreated",
+			},
+			"stale": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"database_definition": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Normalized definition of the materialized view as read back from pg_views",
+			},
+		},
+	}
+}
+
+func resourceRedshiftMaterializedViewExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var name string
+
+	err := client.QueryRow(`
+			SELECT c.relname
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			JOIN svv_mv_info mv ON trim(mv.schema) = n.nspname AND trim(mv.name) = c.relname
+			WHERE c.oid = $1`, d.Id()).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftMaterializedViewCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	schemaName, _, schemaErr := GetSchemaInfoForSchemaId(redshiftClient, d.Get("schema_id").(int))
+	if schemaErr != nil {
+		log.Print(schemaErr)
+		return schemaErr
+	}
+
+	viewName := schemaName + "." + d.Get("view_name").(string)
+
+	var createStatement = "CREATE MATERIALIZED VIEW " + viewName
+
+	if d.Get("backup").(bool) {
+		createStatement += " BACKUP YES"
+	} else {
+		createStatement += " BACKUP NO"
+	}
+	if v, ok := d.GetOk("diststyle"); ok {
+		createStatement += " DISTSTYLE " + v.(string)
+	}
+	if v, ok := d.GetOk("distkey"); ok {
+		createStatement += " DISTKEY(" + v.(string) + ")"
+	}
+	if v, ok := d.GetOk("sortkey"); ok {
+		createStatement += " SORTKEY(" + strings.Join(interfacesToStrings(v.([]interface{})), ", ") + ")"
+	}
+	if d.Get("auto_refresh").(bool) {
+		createStatement += " AUTO REFRESH YES"
+	}
+
+	createStatement += " AS " + strings.TrimRight(strings.TrimSpace(d.Get("definition").(string)), ";")
+
+	log.Print("Create materialized view statement: " + createStatement)
+
+	//Materialized views with auto refresh cannot be created inside a transaction block
+	if _, err := redshiftClient.Exec(createStatement); err != nil {
+		return fmt.Errorf("Could not create redshift materialized view: %s", err)
+	}
+
+	oid, oidErr := getRelationOid(redshiftClient, d.Get("schema_id").(int), d.Get("view_name").(string), "v")
+	if oidErr != nil {
+		return fmt.Errorf("Could not get redshift materialized view id: %s", oidErr)
+	}
+
+	log.Print("Created materialized view with oid: " + oid)
+
+	d.SetId(oid)
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	if v, ok := d.GetOk("select_groups"); ok {
+		if err := grantTableSelectToGroups(tx, viewName, v.(*schema.Set).List()); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error granting select on materialized view; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
+		}
+	}
+
+	readErr := readRedshiftMaterializedView(d, tx, false)
+
+	if readErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading materialized view; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(readErr)
+		return readErr
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func resourceRedshiftMaterializedViewRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	err := readRedshiftMaterializedView(d, tx, true)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading materialized view: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func readRedshiftMaterializedView(d *schema.ResourceData, tx *sql.Tx, detectDrift bool) error {
+	var (
+		viewName    string
+		schemaId    int
+		definition  string
+		autoRefresh string
+		stale       string
+	)
+
+	err := tx.QueryRow(`
+			SELECT trim(c.relname), c.relnamespace, v.definition, trim(mv.autorefresh), trim(mv.is_stale)
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			JOIN pg_views v ON v.schemaname = n.nspname AND v.viewname = c.relname
+			JOIN svv_mv_info mv ON trim(mv.schema) = n.nspname AND trim(mv.name) = c.relname
+			WHERE c.oid = $1`, d.Id()).Scan(&viewName, &schemaId, &definition, &autoRefresh, &stale)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("view_name", viewName)
+	d.Set("schema_id", schemaId)
+	d.Set("auto_refresh", autoRefresh == "t")
+	d.Set("stale", stale == "t")
+
+	selectGroups, err := getTableSelectGroups(tx, d.Id())
+	if err != nil {
+		return err
+	}
+	d.Set("select_groups", selectGroups)
+
+	if err := readRedshiftMaterializedViewStorage(d, tx, schemaId, viewName); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	setDatabaseDefinition(d, definition, detectDrift)
+
+	return nil
+}
+
+/*
+The data of a materialized view is stored in a table called mv_tbl__<view name>__0, which has the
+backup, distribution style, distribution key and sort key of the materialized view.
+stv_tbl_perm is only visible to superusers, so backup is left as it is when it can't be read
+*/
+func readRedshiftMaterializedViewStorage(d *schema.ResourceData, tx *sql.Tx, schemaId int, viewName string) error {
+	var (
+		tableId   int
+		distStyle int
+		backup    sql.NullInt64
+	)
+
+	err := tx.QueryRow(`
+			SELECT c.oid, c.reldiststyle, (SELECT max(backup) FROM stv_tbl_perm WHERE id = c.oid)
+			FROM pg_class c
+			WHERE c.relkind = 'r' AND c.relnamespace = $1 AND c.relname = 'mv_tbl__' || $2 || '__0'`, schemaId, viewName).Scan(&tableId, &distStyle, &backup)
+	if err != nil {
+		return fmt.Errorf("Could not read the storage of materialized view %s: %s", viewName, err)
+	}
+
+	//reldiststyle is 0 EVEN, 1 KEY, 8 ALL and 10, 11 and 12 for AUTO(ALL), AUTO(EVEN) and AUTO(KEY).
+	switch {
+	case distStyle >= 10:
+		d.Set("diststyle", "AUTO")
+	case distStyle == 8:
+		d.Set("diststyle", "ALL")
+	case distStyle == 1:
+		d.Set("diststyle", "KEY")
+	default:
+		d.Set("diststyle", "EVEN")
+	}
+	if backup.Valid {
+		d.Set("backup", backup.Int64 != 0)
+	}
+
+	rows, err := tx.Query(`
+			SELECT trim(attname), attisdistkey, attsortkeyord
+			FROM pg_attribute
+			WHERE attrelid = $1 AND attnum > 0 AND NOT attisdropped
+			ORDER BY attnum`, tableId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var (
+		distkey string
+		sortkey = map[int]string{}
+	)
+
+	for rows.Next() {
+		var (
+			name       string
+			isDistkey  bool
+			sortkeyOrd int
+		)
+		if err := rows.Scan(&name, &isDistkey, &sortkeyOrd); err != nil {
+			return err
+		}
+		if isDistkey {
+			distkey = name
+		}
+		if sortkeyOrd > 0 {
+			sortkey[sortkeyOrd] = name
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var sortkeyColumns = []string{}
+	for i := 1; i <= len(sortkey); i++ {
+		sortkeyColumns = append(sortkeyColumns, sortkey[i])
+	}
+
+	d.Set("distkey", distkey)
+	d.Set("sortkey", sortkeyColumns)
+
+	return nil
+}
+
+func resourceRedshiftMaterializedViewUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	if err := updateRedshiftMaterializedView(tx, d); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error updating materialized view: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	err := readRedshiftMaterializedView(d, tx, false)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading materialized view: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func updateRedshiftMaterializedView(tx *sql.Tx, d *schema.ResourceData) error {
+
+	schemaName, _, schemaErr := GetSchemaInfoForSchemaId(tx, d.Get("schema_id").(int))
+	if schemaErr != nil {
+		return schemaErr
+	}
+
+	if d.HasChange("view_name") {
+		oldName, newName := d.GetChange("view_name")
+		if _, err := tx.Exec("ALTER MATERIALIZED VIEW " + schemaName + "." + oldName.(string) + " RENAME TO " + newName.(string)); err != nil {
+			return err
+		}
+	}
+
+	viewName := schemaName + "." + d.Get("view_name").(string)
+
+	if d.HasChange("auto_refresh") {
+		autoRefresh := "NO"
+		if d.Get("auto_refresh").(bool) {
+			autoRefresh = "YES"
+		}
+		if _, err := tx.Exec("ALTER MATERIALIZED VIEW " + viewName + " AUTO REFRESH " + autoRefresh); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("select_groups") {
+		oldGroups, newGroups := d.GetChange("select_groups")
+
+		if err := revokeTableSelectFromGroups(tx, viewName, difference(oldGroups.(*schema.Set).List(), newGroups.(*schema.Set).List())); err != nil {
+			return err
+		}
+		if err := grantTableSelectToGroups(tx, viewName, difference(newGroups.(*schema.Set).List(), oldGroups.(*schema.Set).List())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func resourceRedshiftMaterializedViewDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	schemaName, _, schemaErr := GetSchemaInfoForSchemaId(client, d.Get("schema_id").(int))
+	if schemaErr != nil {
+		log.Print(schemaErr)
+		return schemaErr
+	}
+
+	_, err := client.Exec("DROP MATERIALIZED VIEW " + schemaName + "." + d.Get("view_name").(string))
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftMaterializedViewImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if err := resourceRedshiftMaterializedViewRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+func grantTableSelectToGroups(tx *sql.Tx, tableName string, groupIds []interface{}) error {
+	for _, v := range groupIds {
+		groupName, err := GetGroupNameForGroupId(tx, v.(int))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("GRANT SELECT ON " + tableName + " TO GROUP " + groupName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func revokeTableSelectFromGroups(tx *sql.Tx, tableName string, groupIds []interface{}) error {
+	for _, v := range groupIds {
+		groupName, err := GetGroupNameForGroupId(tx, v.(int))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("REVOKE SELECT ON " + tableName + " FROM GROUP " + groupName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Groups with select (r) on a table or view, read from entries like group name=arwdRxt/owner in relacl
+func getTableSelectGroups(q Queryer, oid string) ([]int, error) {
+
+	rows, err := q.Query(`
+			SELECT pu.grosysid
+			FROM pg_group pu, pg_class c
+			WHERE c.oid = $1
+			AND charindex('r', split_part(split_part(array_to_string(c.relacl, '|'), 'group ' || pu.groname || '=', 2), '/', 1)) > 0`, oid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groupIds = []int{}
+	for rows.Next() {
+		var groupId int
+		if err := rows.Scan(&groupId); err != nil {
+			return nil, err
+		}
+		groupIds = append(groupIds, groupId)
+	}
+
+	return groupIds, rows.Err()
+}
+
+func interfacesToStrings(values []interface{}) []string {
+	var strs = make([]string, 0, len(values))
+	for _, v := range values {
+		strs = append(strs, v.(string))
+	}
+	return strs
+}
@@ -0,0 +1,553 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/lib/pq"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_PROCEDURE.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_ALTER_PROCEDURE.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_DROP_PROCEDURE.html
+
+/*
+The id is the oid of the procedure, which CREATE OR REPLACE keeps as long as the signature (name and
+input argument types) does not change. Changing the arguments creates a new procedure.
+*/
+func redshiftProcedure() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftProcedureCreate,
+		Read:   resourceRedshiftProcedureRead,
+		Update: resourceRedshiftProcedureUpdate,
+		Delete: resourceRedshiftProcedureDelete,
+		Exists: resourceRedshiftProcedureExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftProcedureImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"procedure_name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"schema_id": {
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			"argument": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: true,
+						},
+						"mode": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							Default:      "IN",
+							ValidateFunc: validation.StringInSlice([]string{"IN", "OUT", "INOUT"}, false),
+						},
+						"type": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+					},
+				},
+			},
+			"body": {
+				Type:             schema.TypeString,
+				Required:         true,
+				DiffSuppressFunc: suppressEquivalentSqlDiffs,
+				Description:      "The plpgsql body of the procedure, without the $$ quoting",
+			},
+			"nonatomic": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Run the procedure in nonatomic mode, where statements are committed automatically",
+			},
+			"security": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "INVOKER",
+				ValidateFunc: validation.StringInSlice([]string{"INVOKER", "DEFINER"}, false),
+			},
+			"config": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Configuration parameters to SET when the procedure runs, eg search_path",
+			},
+			"owner": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+				Description: "Defaults to user specified in provider",
+			},
+		},
+	}
+}
+
+func resourceRedshiftProcedureExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var name string
+
+	err := client.QueryRow("SELECT proname FROM pg_proc_info WHERE prokind = 'p' AND prooid = $1", d.Id()).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftProcedureCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	if err := createOrReplaceProcedure(tx, d); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error creating procedure; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not create redshift procedure: %s", err)
+	}
+
+	signature, signatureErr := routineSignature(tx, d, "procedure_name")
+	if signatureErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting schema info; unable to rollback: %v", rollbackErr)
+		}
+		return signatureErr
+	}
+
+	oid, oidErr := getRoutineOid(tx, signature)
+	if oidErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting procedure oid; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not get redshift procedure id: %s", oidErr)
+	}
+
+	d.SetId(oid)
+
+	if v, ok := d.GetOk("owner"); ok {
+		var usernames = GetUsersnamesForUsesysid(tx, []interface{}{v.(int)})
+		if _, err := tx.Exec("ALTER PROCEDURE " + signature + " OWNER TO " + usernames[0]); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error setting procedure owner; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
+		}
+	}
+
+	readErr := readRedshiftProcedure(d, tx)
+
+	if readErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading procedure; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(readErr)
+		return readErr
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func resourceRedshiftProcedureRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	err := readRedshiftProcedure(d, tx)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading procedure: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func readRedshiftProcedure(d *schema.ResourceData, tx *sql.Tx) error {
+	var (
+		procedureName string
+		schemaId      int
+		owner         int
+		body          string
+		securityDef   bool
+		config        sql.NullString
+	)
+
+	err := tx.QueryRow(`
+			SELECT trim(proname), pronamespace, proowner, prosrc, prosecdef, array_to_string(proconfig, '|')
+			FROM pg_proc_info
+			WHERE prokind = 'p' AND prooid = $1`, d.Id()).Scan(&procedureName, &schemaId, &owner, &body, &securityDef, &config)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("procedure_name", procedureName)
+	d.Set("schema_id", schemaId)
+	d.Set("owner", owner)
+
+	if securityDef {
+		d.Set("security", "DEFINER")
+	} else {
+		d.Set("security", "INVOKER")
+	}
+
+	//prosrc is the body exactly as it was created, so any difference is a change made outside terraform
+	if normalizeSqlDefinition(body) != normalizeSqlDefinition(d.Get("body").(string)) {
+		d.Set("body", body)
+	}
+
+	//proconfig has the parameters as name=value, with the values quoted and spaced the way the database stores them
+	var configured = d.Get("config").(map[string]interface{})
+	var actual = map[string]interface{}{}
+	for _, setting := range strings.Split(config.String, "|") {
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if v, ok := configured[parts[0]]; ok && normalizeConfigValue(v.(string)) == normalizeConfigValue(parts[1]) {
+			actual[parts[0]] = v
+		} else {
+			actual[parts[0]] = parts[1]
+		}
+	}
+	d.Set("config", actual)
+
+	return nil
+}
+
+func resourceRedshiftProcedureUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	if err := updateRedshiftProcedure(tx, d); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error updating procedure: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	err := readRedshiftProcedure(d, tx)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading procedure: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func updateRedshiftProcedure(tx *sql.Tx, d *schema.ResourceData) error {
+
+	if d.HasChange("procedure_name") {
+		oldName, newName := d.GetChange("procedure_name")
+
+		schemaName, _, err := GetSchemaInfoForSchemaId(tx, d.Get("schema_id").(int))
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("ALTER PROCEDURE " + schemaName + "." + oldName.(string) + routineArgumentTypes(d) + " RENAME TO " + newName.(string)); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("body") || d.HasChange("nonatomic") || d.HasChange("security") || d.HasChange("config") {
+		if err := createOrReplaceProcedure(tx, d); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("owner") {
+		signature, err := routineSignature(tx, d, "procedure_name")
+		if err != nil {
+			return err
+		}
+
+		var usernames = GetUsersnamesForUsesysid(tx, []interface{}{d.Get("owner").(int)})
+		if _, err := tx.Exec("ALTER PROCEDURE " + signature + " OWNER TO " + usernames[0]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createOrReplaceProcedure(tx *sql.Tx, d *schema.ResourceData) error {
+
+	schemaName, _, err := GetSchemaInfoForSchemaId(tx, d.Get("schema_id").(int))
+	if err != nil {
+		return err
+	}
+
+	var createStatement = "CREATE OR REPLACE PROCEDURE " + schemaName + "." + d.Get("procedure_name").(string) + routineArguments(d)
+
+	if d.Get("nonatomic").(bool) {
+		createStatement += " NONATOMIC"
+	}
+
+	createStatement += " AS $$" + d.Get("body").(string) + "$$ LANGUAGE plpgsql SECURITY " + d.Get("security").(string)
+
+	config := d.Get("config").(map[string]interface{})
+	var parameters []string
+	for parameter := range config {
+		parameters = append(parameters, parameter)
+	}
+	sort.Strings(parameters)
+
+	for _, parameter := range parameters {
+		createStatement += " SET " + parameter + " TO " + quoteConfigValue(config[parameter].(string))
+	}
+
+	log.Print("Create procedure statement: " + createStatement)
+
+	_, err = tx.Exec(createStatement)
+	return err
+}
+
+// Quotes each value of a list separately, otherwise a search_path of "a, b" would be a single schema called "a, b"
+func quoteConfigValue(value string) string {
+
+	var values []string
+
+	for _, v := range strings.Split(value, ",") {
+		values = append(values, pq.QuoteLiteral(strings.TrimSpace(v)))
+	}
+
+	return strings.Join(values, ", ")
+}
+
+// Drops the quotes and spaces the database adds to configuration values, eg "$user", public for $user,public
+func normalizeConfigValue(value string) string {
+
+	var values []string
+
+	for _, v := range strings.Split(value, ",") {
+		values = append(values, strings.Trim(strings.TrimSpace(v), `"'`))
+	}
+
+	return strings.Join(values, ",")
+}
+
+func resourceRedshiftProcedureDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	signature, err := routineSignature(client, d, "procedure_name")
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	if _, err := client.Exec("DROP PROCEDURE " + signature); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftProcedureImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if err := importRedshiftRoutineArguments(d, meta.(*Client).db, true); err != nil {
+		return nil, err
+	}
+	if err := resourceRedshiftProcedureRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+// Full argument list for CREATE, eg (id IN integer, total OUT bigint)
+func routineArguments(d *schema.ResourceData) string {
+
+	var arguments []string
+
+	for _, a := range d.Get("argument").([]interface{}) {
+		argument := a.(map[string]interface{})
+
+		var definition string
+		if name, ok := argument["name"]; ok && name.(string) != "" {
+			definition += name.(string) + " "
+		}
+		if mode, ok := argument["mode"]; ok && mode.(string) != "" {
+			definition += mode.(string) + " "
+		}
+		definition += argument["type"].(string)
+
+		arguments = append(arguments, definition)
+	}
+
+	return "(" + strings.Join(arguments, ", ") + ")"
+}
+
+// Types of the input arguments, which are what identify a procedure or function, eg (integer)
+func routineArgumentTypes(d *schema.ResourceData) string {
+
+	var types []string
+
+	for _, a := range d.Get("argument").([]interface{}) {
+		argument := a.(map[string]interface{})
+		if mode, ok := argument["mode"]; ok && mode.(string) == "OUT" {
+			continue
+		}
+		types = append(types, argument["type"].(string))
+	}
+
+	return "(" + strings.Join(types, ", ") + ")"
+}
+
+// Qualified signature used to alter or drop a procedure or function, eg schema.name(integer)
+func routineSignature(q Queryer, d *schema.ResourceData, nameAttribute string) (string, error) {
+
+	schemaName, _, err := GetSchemaInfoForSchemaId(q, d.Get("schema_id").(int))
+	if err != nil {
+		return "", err
+	}
+
+	return schemaName + "." + d.Get(nameAttribute).(string) + routineArgumentTypes(d), nil
+}
+
+func getRoutineOid(q Queryer, signature string) (string, error) {
+
+	var oid int
+
+	err := q.QueryRow("SELECT $1::regprocedure::oid", signature).Scan(&oid)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(oid), nil
+}
+
+// The arguments are part of the signature, so on import they have to be read back before anything else.
+// Only input arguments are imported, OUT arguments have to be added to the configuration by hand.
+// Functions only have input arguments, so their arguments have no mode
+func importRedshiftRoutineArguments(d *schema.ResourceData, q Queryer, withModes bool) error {
+	var (
+		argNames sql.NullString
+		argModes sql.NullString
+		argTypes string
+	)
+
+	err := q.QueryRow(`
+			SELECT array_to_string(proargnames, ','), array_to_string(proargmodes, ','), oidvectortypes(proargtypes)
+			FROM pg_proc_info
+			WHERE prooid = $1`, d.Id()).Scan(&argNames, &argModes, &argTypes)
+	if err != nil {
+		return err
+	}
+
+	var (
+		names     = splitNonEmpty(argNames.String)
+		modes     = splitNonEmpty(argModes.String)
+		types     = splitNonEmpty(argTypes)
+		arguments []interface{}
+	)
+
+	//Names and modes include OUT arguments, proargtypes only has the inputs
+	var input int
+	for i := 0; input < len(types); i++ {
+		mode := "IN"
+		if i < len(modes) {
+			mode = map[string]string{"i": "IN", "o": "OUT", "b": "INOUT"}[modes[i]]
+		}
+		if mode == "OUT" {
+			continue
+		}
+
+		var name string
+		if i < len(names) {
+			name = names[i]
+		}
+
+		argument := map[string]interface{}{
+			"name": name,
+			"type": types[input],
+		}
+		if withModes {
+			argument["mode"] = mode
+		}
+
+		arguments = append(arguments, argument)
+		input++
+	}
+
+	d.Set("argument", arguments)
+
+	return nil
+}
+
+func splitNonEmpty(s string) []string {
+
+	var parts []string
+
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return parts
+}
@@ -0,0 +1,39 @@
+package redshift
+
+import (
+	"testing"
+)
+
+func TestQuoteConfigValue(t *testing.T) {
+	var cases = []struct {
+		value  string
+		quoted string
+	}{
+		{"testschema", "'testschema'"},
+		{"$user, public", "'$user', 'public'"},
+		{"it's", "'it''s'"},
+	}
+
+	for _, c := range cases {
+		if quoted := quoteConfigValue(c.value); quoted != c.quoted {
+			t.Fatalf("expected %s to be quoted as %s, got %s", c.value, c.quoted, quoted)
+		}
+	}
+}
+
+func TestNormalizeConfigValue(t *testing.T) {
+	var cases = []struct {
+		configured string
+		stored     string
+	}{
+		{"testschema", "testschema"},
+		{"$user,public", `"$user", public`},
+		{"'$user', 'public'", `"$user", public`},
+	}
+
+	for _, c := range cases {
+		if normalizeConfigValue(c.configured) != normalizeConfigValue(c.stored) {
+			t.Fatalf("expected %s to be equivalent to %s", c.configured, c.stored)
+		}
+	}
+}
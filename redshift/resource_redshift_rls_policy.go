@@ -0,0 +1,231 @@
+package redshift
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_RLS_POLICY.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_ALTER_RLS_POLICY.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_SVV_RLS_POLICY.html
+
+/*
+Row-level security policies. Like masking policies they have no oid, so the id is the policy name.
+Policies are attached to tables with redshift_rls_policy_attachment, and only apply to tables with
+row_level_security enabled.
+
+Redshift rewrites the predicate, so the one read back from svv_rls_policy is not the same as the
+configured one. Like the definition of views, the normalized predicate read back is kept in
+database_definition, and the policy is only considered to have drifted when that changes.
+*/
+func redshiftRlsPolicy() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftRlsPolicyCreate,
+		Read:   resourceRedshiftRlsPolicyRead,
+		Update: resourceRedshiftRlsPolicyUpdate,
+		Delete: resourceRedshiftRlsPolicyDelete,
+		Exists: resourceRedshiftRlsPolicyExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftRlsPolicyImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"policy_name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"input_column": { //Columns of the tables the policy is attached to that the predicate uses
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+						"type": {
+							Type:             schema.TypeString,
+							Required:         true,
+							ForceNew:         true,
+							DiffSuppressFunc: suppressEquivalentColumnTypes,
+						},
+					},
+				},
+			},
+			"relation_alias": { //Only used together with the input columns
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				RequiredWith: []string{"input_column"},
+			},
+			"using": {
+				Type:             schema.TypeString,
+				Required:         true,
+				DiffSuppressFunc: suppressEquivalentSqlDiffs,
+				Description:      "Predicate that rows have to match to be visible, eg tenant_id = current_user",
+			},
+			"database_definition": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Normalized predicate of the policy as read back from svv_rls_policy",
+			},
+		},
+	}
+}
+
+func resourceRedshiftRlsPolicyExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var name string
+
+	err := client.QueryRow("SELECT polname FROM svv_rls_policy WHERE polname = $1", d.Id()).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftRlsPolicyCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	var createStatement = "CREATE RLS POLICY " + d.Get("policy_name").(string)
+
+	if v, ok := d.GetOk("input_column"); ok {
+		var inputColumns []string
+		for _, c := range v.([]interface{}) {
+			column := c.(map[string]interface{})
+			inputColumns = append(inputColumns, column["name"].(string)+" "+column["type"].(string))
+		}
+		createStatement += " WITH (" + strings.Join(inputColumns, ", ") + ")"
+
+		if alias, ok := d.GetOk("relation_alias"); ok {
+			createStatement += " AS " + alias.(string)
+		}
+	}
+
+	createStatement += " USING (" + d.Get("using").(string) + ")"
+
+	log.Print("Create rls policy statement: " + createStatement)
+
+	if _, err := redshiftClient.Exec(createStatement); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.SetId(d.Get("policy_name").(string))
+
+	return readRedshiftRlsPolicy(d, redshiftClient, false)
+}
+
+func resourceRedshiftRlsPolicyRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	return readRedshiftRlsPolicy(d, redshiftClient, true)
+}
+
+func readRedshiftRlsPolicy(d *schema.ResourceData, q Queryer, detectDrift bool) error {
+	var (
+		policyName       string
+		alias            sql.NullString
+		inputColumnsJson sql.NullString
+		using            string
+	)
+
+	err := q.QueryRow(`
+			SELECT trim(polname), trim(polalias), json_serialize(polatts), polqual
+			FROM svv_rls_policy
+			WHERE polname = $1`, d.Id()).Scan(&policyName, &alias, &inputColumnsJson, &using)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("policy_name", policyName)
+	d.Set("relation_alias", alias.String)
+
+	//The types are stored in their canonical form, so only set them on import
+	if len(d.Get("input_column").([]interface{})) == 0 && inputColumnsJson.Valid {
+		var inputColumns []struct {
+			Name string `json:"colname"`
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(inputColumnsJson.String), &inputColumns); err != nil {
+			return fmt.Errorf("Could not parse input columns of rls policy %s: %s", policyName, err)
+		}
+
+		var columns []interface{}
+		for _, c := range inputColumns {
+			columns = append(columns, map[string]interface{}{"name": c.Name, "type": c.Type})
+		}
+		d.Set("input_column", columns)
+	}
+
+	//The configured predicate is only replaced, which shows up as a diff, when the predicate in the
+	//database has changed since it was last applied or read
+	var databaseDefinition = normalizeSqlDefinition(using)
+	var previous = d.Get("database_definition").(string)
+
+	if detectDrift && previous != "" && previous != databaseDefinition {
+		log.Printf("Predicate of rls policy %s has changed outside of terraform", policyName)
+		d.Set("using", using)
+	}
+
+	//On import there is no configured predicate yet
+	if d.Get("using").(string) == "" {
+		d.Set("using", using)
+	}
+
+	d.Set("database_definition", databaseDefinition)
+
+	return nil
+}
+
+func resourceRedshiftRlsPolicyUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	if d.HasChange("using") {
+		if _, err := redshiftClient.Exec("ALTER RLS POLICY " + d.Get("policy_name").(string) + " USING (" + d.Get("using").(string) + ")"); err != nil {
+			log.Print(err)
+			return err
+		}
+	}
+
+	return readRedshiftRlsPolicy(d, redshiftClient, false)
+}
+
+func resourceRedshiftRlsPolicyDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	//Fails while the policy is still attached, the attachments are removed first when managed in the same configuration
+	if _, err := client.Exec("DROP RLS POLICY " + d.Get("policy_name").(string)); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftRlsPolicyImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if err := resourceRedshiftRlsPolicyRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,208 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_ATTACH_RLS_POLICY.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_DETACH_RLS_POLICY.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_SVV_RLS_ATTACHED_POLICY.html
+
+/*
+Id is policy_name:table_id:user:<usesysid>, policy_name:table_id:role:<role name> or policy_name:table_id:public,
+which is also the format used for import
+*/
+func redshiftRlsPolicyAttachment() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftRlsPolicyAttachmentCreate,
+		Read:   resourceRedshiftRlsPolicyAttachmentRead,
+		Delete: resourceRedshiftRlsPolicyAttachmentDelete,
+		Exists: resourceRedshiftRlsPolicyAttachmentExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftRlsPolicyAttachmentImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"policy_name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"table_id": {
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			"user": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"user", "role", "public"},
+			},
+			"role": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"user", "role", "public"},
+			},
+			"public": {
+				Type:         schema.TypeBool,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{"user", "role", "public"},
+			},
+		},
+	}
+}
+
+func resourceRedshiftRlsPolicyAttachmentExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	_, err := queryRlsPolicyAttachment(client, d)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftRlsPolicyAttachmentCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	schemaName, tableName, err := getRelationName(redshiftClient, d.Get("table_id").(int))
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	grantee, err := policyGrantee(redshiftClient, d)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("User %d does not exist", d.Get("user").(int))
+	}
+	if err != nil {
+		return err
+	}
+
+	var attachStatement = "ATTACH RLS POLICY " + d.Get("policy_name").(string) + " ON " + schemaName + "." + tableName + " TO " + grantee
+
+	log.Print("Attach rls policy statement: " + attachStatement)
+
+	if _, err := redshiftClient.Exec(attachStatement); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.SetId(d.Get("policy_name").(string) + ":" + strconv.Itoa(d.Get("table_id").(int)) + ":" + policyGranteeId(d))
+
+	return readRedshiftRlsPolicyAttachment(d, redshiftClient)
+}
+
+func resourceRedshiftRlsPolicyAttachmentRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	err := readRedshiftRlsPolicyAttachment(d, redshiftClient)
+	if err == sql.ErrNoRows {
+		//The policy was detached, or the table or user dropped, outside terraform
+		log.Printf("Rls policy attachment %s not found, removing it from the state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	return err
+}
+
+func readRedshiftRlsPolicyAttachment(d *schema.ResourceData, q Queryer) error {
+
+	policyName, err := queryRlsPolicyAttachment(q, d)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("policy_name", strings.TrimSpace(policyName))
+
+	return nil
+}
+
+func queryRlsPolicyAttachment(q Queryer, d *schema.ResourceData) (string, error) {
+
+	granteeType, grantee, err := policyGranteeKind(q, d)
+	if err != nil {
+		return "", err
+	}
+
+	var policyName string
+
+	err = q.QueryRow(`
+			SELECT a.polname
+			FROM svv_rls_attached_policy a
+			JOIN pg_namespace n ON n.nspname = trim(a.relschema)
+			JOIN pg_class c ON c.relnamespace = n.oid AND c.relname = trim(a.relname)
+			WHERE a.polname = $1 AND c.oid = $2 AND a.granteekind = $3 AND trim(a.grantee) = $4`,
+		d.Get("policy_name").(string), d.Get("table_id").(int), granteeType, grantee).Scan(&policyName)
+
+	return policyName, err
+}
+
+func resourceRedshiftRlsPolicyAttachmentDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	schemaName, tableName, err := getRelationName(client, d.Get("table_id").(int))
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	grantee, err := policyGrantee(client, d)
+	if err == sql.ErrNoRows {
+		//Policies are detached when the user is dropped
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Exec("DETACH RLS POLICY " + d.Get("policy_name").(string) + " ON " + schemaName + "." + tableName + " FROM " + grantee); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftRlsPolicyAttachmentImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+
+	parts := strings.SplitN(d.Id(), ":", 4)
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("Unexpected import id %s, expected policy_name:table_id:user:<usesysid>, policy_name:table_id:role:<role name> or policy_name:table_id:public", d.Id())
+	}
+
+	tableId, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("Unexpected table id %s in import id %s", parts[1], d.Id())
+	}
+
+	d.Set("policy_name", parts[0])
+	d.Set("table_id", tableId)
+
+	if err := importPolicyGrantee(d, parts[2:]); err != nil {
+		return nil, err
+	}
+
+	if err := resourceRedshiftRlsPolicyAttachmentRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
@@ -135,13 +135,31 @@ func resourceRedshiftSchemaGroupPrivilegeCreate(d *schema.ResourceData, meta int
 		return schemaErr
 	}
 
-	if isSystemSchema(schemaOwner) {
+	external, externalErr := isExternalSchema(tx, d.Get("schema_id").(int))
+	if externalErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting schema info: rollback failed: %v", rollbackErr)
+		}
+		log.Print(externalErr)
+		return externalErr
+	}
+
+	if !external && isSystemSchema(schemaOwner) {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
 			log.Printf("error getting schema info: rollback failed: %v", rollbackErr)
 		}
 		return NewError("Privilege creation is not allowed for system schemas, schema=" + schemaName)
 	}
 
+	if external {
+		if err := validateExternalSchemaGrants(d, schemaName); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error validating external schema grants: rollback failed: %v", rollbackErr)
+			}
+			return err
+		}
+	}
+
 	groupName, groupErr := GetGroupNameForGroupId(tx, d.Get("group_id").(int))
 	if groupErr != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
@@ -330,6 +348,24 @@ func resourceRedshiftSchemaGroupPrivilegeUpdate(d *schema.ResourceData, meta int
 		return schemaErr
 	}
 
+	external, externalErr := isExternalSchema(tx, d.Get("schema_id").(int))
+	if externalErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting schema info for schema ID; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(externalErr)
+		return externalErr
+	}
+
+	if external {
+		if err := validateExternalSchemaGrants(d, schemaName); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error validating external schema grants; unable to rollback: %v", rollbackErr)
+			}
+			return err
+		}
+	}
+
 	groupName, groupErr := GetGroupNameForGroupId(tx, d.Get("group_id").(int))
 	if groupErr != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
@@ -426,20 +462,32 @@ func resourceRedshiftSchemaGroupPrivilegeDelete(d *schema.ResourceData, meta int
 		return groupErr
 	}
 
-	if _, err := tx.Exec("REVOKE ALL ON  ALL TABLES IN SCHEMA " + schemaName + " FROM GROUP " + groupName); err != nil {
+	external, externalErr := isExternalSchema(tx, d.Get("schema_id").(int))
+	if externalErr != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
-			log.Printf("error revoking privileges; unable to rollback: %v", rollbackErr)
+			log.Printf("error getting schema info for schema ID; unable to rollback: %v", rollbackErr)
 		}
-		log.Print(err)
-		return err
+		log.Print(externalErr)
+		return externalErr
 	}
 
-	if _, err := tx.Exec("ALTER DEFAULT PRIVILEGES IN SCHEMA " + schemaName + " REVOKE ALL ON TABLES FROM GROUP " + groupName); err != nil {
-		if rollbackErr := tx.Rollback(); rollbackErr != nil {
-			log.Printf("error altering default privileges; unable to rollback: %v", rollbackErr)
+	//Table privileges on external schemas are managed through Lake Formation, and default privileges are not supported
+	if !external {
+		if _, err := tx.Exec("REVOKE ALL ON  ALL TABLES IN SCHEMA " + schemaName + " FROM GROUP " + groupName); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error revoking privileges; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
+		}
+
+		if _, err := tx.Exec("ALTER DEFAULT PRIVILEGES IN SCHEMA " + schemaName + " REVOKE ALL ON TABLES FROM GROUP " + groupName); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error altering default privileges; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
 		}
-		log.Print(err)
-		return err
 	}
 
 	if _, err := tx.Exec("REVOKE ALL ON SCHEMA " + schemaName + " FROM GROUP " + groupName); err != nil {
@@ -493,6 +541,30 @@ func isSystemSchema(schemaOwner int) bool {
 	return schemaOwner == 1
 }
 
+// External schemas are listed in svv_external_schemas as well as pg_namespace
+func isExternalSchema(q Queryer, schemaId int) (bool, error) {
+
+	var count int
+
+	err := q.QueryRow("SELECT count(*) FROM svv_external_schemas WHERE esoid = $1", schemaId).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Only USAGE can be granted on an external schema. Table level access is managed through
+// Lake Formation or GRANT ... ON EXTERNAL TABLE, and ALTER DEFAULT PRIVILEGES is not valid
+func validateExternalSchemaGrants(d *schema.ResourceData, schemaName string) error {
+	if len(validateGrants(d)) > 0 {
+		return NewError("Table privileges cannot be granted on external schemas, only usage is supported, schema=" + schemaName)
+	}
+	if v, ok := d.GetOk("create"); ok && v.(bool) {
+		return NewError("Create privilege cannot be granted on external schemas, only usage is supported, schema=" + schemaName)
+	}
+	return nil
+}
+
 func updateSchemaPrivilege(tx *sql.Tx, d *schema.ResourceData, attribute string, privilege string, schemaName string, groupName string) error {
 	if !d.HasChange(attribute) {
 		return nil
@@ -0,0 +1,774 @@
+package redshift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_TABLE_NEW.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_ALTER_TABLE.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_DROP_TABLE.html
+
+/*
+Permanent tables only. The id is the oid in pg_class, so tables can be renamed in place.
+
+Columns, distribution and sort keys are read back from the catalog tables pg_table_def is built on,
+since pg_table_def only lists tables in schemas on the search_path. Changes that ALTER TABLE cannot
+make (changing a column type other than widening a varchar, nullability or defaults, reordering
+columns, interleaved sort keys and backup) recreate the table.
+*/
+func redshiftTable() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceRedshiftTableCreate,
+		Read:          resourceRedshiftTableRead,
+		Update:        resourceRedshiftTableUpdate,
+		Delete:        resourceRedshiftTableDelete,
+		Exists:        resourceRedshiftTableExists,
+		CustomizeDiff: resourceRedshiftTableCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftTableImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"table_name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"schema_id": { //Tables cannot be moved to another schema
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			"owner": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+				Description: "Defaults to user specified in provider",
+			},
+			"column": {
+				Type:     schema.TypeList,
+				Required: true,
+				MinItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"type": {
+							Type:             schema.TypeString,
+							Required:         true,
+							DiffSuppressFunc: suppressEquivalentColumnTypes,
+						},
+						"encoding": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Computed:         true,
+							DiffSuppressFunc: suppressCaseDiffs,
+							Description:      "Compression encoding, eg az64, lzo, zstd, raw. Defaults to AUTO",
+						},
+						"nullable": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  true,
+						},
+						"default": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Default expression. This is not read back from the database",
+						},
+					},
+				},
+			},
+			"diststyle": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice([]string{"AUTO", "EVEN", "KEY", "ALL"}, false),
+			},
+			"distkey": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+			"sortkey_style": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice([]string{"AUTO", "COMPOUND", "INTERLEAVED"}, false),
+			},
+			"sortkey": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"backup": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+				ForceNew: true,
+			},
+			"row_level_security": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Only show rows matching the rls policies attached to the table",
+			},
+			"row_level_security_conjunction_type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "AND",
+				ValidateFunc: validation.StringInSlice([]string{"AND", "OR"}, false),
+				Description:  "How the rls policies attached to the table for a user are combined",
+			},
+			"cascade_on_delete": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Drop objects that depend on the table, such as views",
+			},
+		},
+	}
+}
+
+func resourceRedshiftTableExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var name string
+
+	err := client.QueryRow("SELECT relname FROM pg_class WHERE relkind = 'r' AND oid = $1", d.Id()).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftTableCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	schemaName, _, schemaErr := GetSchemaInfoForSchemaId(tx, d.Get("schema_id").(int))
+	if schemaErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting schema info: rollback failed: %v", rollbackErr)
+		}
+		log.Print(schemaErr)
+		return schemaErr
+	}
+
+	tableName := schemaName + "." + d.Get("table_name").(string)
+
+	var columnDefinitions []string
+	for _, c := range d.Get("column").([]interface{}) {
+		columnDefinitions = append(columnDefinitions, columnDefinition(c.(map[string]interface{})))
+	}
+
+	var createStatement = "CREATE TABLE " + tableName + " (" + strings.Join(columnDefinitions, ", ") + ")"
+
+	if !d.Get("backup").(bool) {
+		createStatement += " BACKUP NO"
+	}
+	if v, ok := d.GetOk("diststyle"); ok {
+		createStatement += " DISTSTYLE " + v.(string)
+	}
+	if v, ok := d.GetOk("distkey"); ok {
+		createStatement += " DISTKEY(" + v.(string) + ")"
+	}
+
+	sortkey := interfacesToStrings(d.Get("sortkey").([]interface{}))
+	switch style := d.Get("sortkey_style").(string); {
+	case style == "AUTO":
+		createStatement += " SORTKEY AUTO"
+	case len(sortkey) > 0:
+		createStatement += " " + style + " SORTKEY(" + strings.Join(sortkey, ", ") + ")"
+	}
+
+	log.Print("Create table statement: " + createStatement)
+
+	if _, err := tx.Exec(createStatement); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error creating table; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not create redshift table: %s", err)
+	}
+
+	if v, ok := d.GetOk("owner"); ok {
+		var usernames = GetUsersnamesForUsesysid(tx, []interface{}{v.(int)})
+		if _, err := tx.Exec("ALTER TABLE " + tableName + " OWNER TO " + usernames[0]); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error setting table owner; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
+		}
+	}
+
+	if d.Get("row_level_security").(bool) {
+		if _, err := tx.Exec("ALTER TABLE " + tableName + rowLevelSecurityClause(d)); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error turning on row level security; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
+		}
+	}
+
+	//The changes do not propagate instantly
+	time.Sleep(5 * time.Second)
+
+	oid, oidErr := getRelationOid(tx, d.Get("schema_id").(int), d.Get("table_name").(string), "r")
+	if oidErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting table oid; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not get redshift table id: %s", oidErr)
+	}
+
+	log.Print("Created table with oid: " + oid)
+
+	d.SetId(oid)
+
+	readErr := readRedshiftTable(d, tx)
+
+	if readErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading table; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(readErr)
+		return readErr
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func resourceRedshiftTableRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	return readRedshiftTable(d, redshiftClient)
+}
+
+func readRedshiftTable(d *schema.ResourceData, q Queryer) error {
+	var (
+		tableName   string
+		schemaId    int
+		owner       int
+		distStyle   int
+		sortkeyInfo sql.NullString
+		backup      sql.NullInt64
+	)
+
+	//reldiststyle is 0 EVEN, 1 KEY, 8 ALL and 10, 11 and 12 for AUTO(ALL), AUTO(EVEN) and AUTO(KEY).
+	//svv_table_info only has rows for tables with data, and stv_tbl_perm is only visible to superusers
+	err := q.QueryRow(`
+			SELECT trim(c.relname), c.relnamespace, c.relowner, c.reldiststyle, ti.sortkey1,
+				(SELECT max(backup) FROM stv_tbl_perm WHERE id = c.oid)
+			FROM pg_class c
+			LEFT JOIN svv_table_info ti ON ti.table_id = c.oid
+			WHERE c.relkind = 'r' AND c.oid = $1`, d.Id()).Scan(&tableName, &schemaId, &owner, &distStyle, &sortkeyInfo, &backup)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("table_name", tableName)
+	d.Set("schema_id", schemaId)
+	d.Set("owner", owner)
+
+	switch {
+	case distStyle >= 10:
+		d.Set("diststyle", "AUTO")
+	case distStyle == 8:
+		d.Set("diststyle", "ALL")
+	case distStyle == 1:
+		d.Set("diststyle", "KEY")
+	default:
+		d.Set("diststyle", "EVEN")
+	}
+
+	if backup.Valid {
+		d.Set("backup", backup.Int64 != 0)
+	}
+
+	if err := readRedshiftTableRowLevelSecurity(d, q); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	rows, err := q.Query(`
+			SELECT trim(a.attname), format_type(a.atttypid, a.atttypmod), format_encoding(a.attencodingtype::integer),
+				a.attnotnull, a.attisdistkey, a.attsortkeyord
+			FROM pg_attribute a
+			WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+			ORDER BY a.attnum`, d.Id())
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+	defer rows.Close()
+
+	var (
+		configured  = columnsByName(d.Get("column").([]interface{}))
+		columns     []interface{}
+		distkey     string
+		sortkey     = map[int]string{}
+		interleaved bool
+	)
+
+	for rows.Next() {
+		var (
+			name        string
+			columnType  string
+			encoding    string
+			notNull     bool
+			isDistkey   bool
+			sortkeyOrd  int
+			defaultExpr string
+		)
+		if err := rows.Scan(&name, &columnType, &encoding, &notNull, &isDistkey, &sortkeyOrd); err != nil {
+			return err
+		}
+
+		//Keep the configured spelling of equivalent types, and the configured default which is not read back
+		if c, ok := configured[name]; ok {
+			if normalizeColumnType(c["type"].(string)) == normalizeColumnType(columnType) {
+				columnType = c["type"].(string)
+			}
+			defaultExpr = c["default"].(string)
+		}
+
+		columns = append(columns, map[string]interface{}{
+			"name":     name,
+			"type":     columnType,
+			"encoding": encoding,
+			"nullable": !notNull,
+			"default":  defaultExpr,
+		})
+
+		if isDistkey {
+			distkey = name
+		}
+		//Interleaved sort key columns have a negative position
+		if sortkeyOrd < 0 {
+			interleaved = true
+			sortkeyOrd = -sortkeyOrd
+		}
+		if sortkeyOrd > 0 {
+			sortkey[sortkeyOrd] = name
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	d.Set("column", columns)
+	d.Set("distkey", distkey)
+
+	var sortkeyColumns = []string{}
+	for i := 1; i <= len(sortkey); i++ {
+		sortkeyColumns = append(sortkeyColumns, sortkey[i])
+	}
+	d.Set("sortkey", sortkeyColumns)
+
+	switch {
+	case strings.HasPrefix(strings.ToUpper(sortkeyInfo.String), "AUTO"):
+		d.Set("sortkey_style", "AUTO")
+	case interleaved:
+		d.Set("sortkey_style", "INTERLEAVED")
+	case len(sortkeyColumns) > 0:
+		d.Set("sortkey_style", "COMPOUND")
+	}
+
+	return nil
+}
+
+func resourceRedshiftTableUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	schemaName, _, schemaErr := GetSchemaInfoForSchemaId(redshiftClient, d.Get("schema_id").(int))
+	if schemaErr != nil {
+		log.Print(schemaErr)
+		return schemaErr
+	}
+
+	//ALTER TABLE cannot alter column types, distribution or sort keys inside a transaction block,
+	//so unlike other resources the changes are not made in a transaction
+	if err := updateRedshiftTable(redshiftClient, d, schemaName); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return readRedshiftTable(d, redshiftClient)
+}
+
+func updateRedshiftTable(db *sql.DB, d *schema.ResourceData, schemaName string) error {
+
+	if d.HasChange("table_name") {
+		oldName, newName := d.GetChange("table_name")
+		if _, err := db.Exec("ALTER TABLE " + schemaName + "." + oldName.(string) + " RENAME TO " + newName.(string)); err != nil {
+			return err
+		}
+	}
+
+	tableName := schemaName + "." + d.Get("table_name").(string)
+
+	if d.HasChange("owner") {
+		var usernames = GetUsersnamesForUsesysid(db, []interface{}{d.Get("owner").(int)})
+		if _, err := db.Exec("ALTER TABLE " + tableName + " OWNER TO " + usernames[0]); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("column") {
+		oldColumns, newColumns := d.GetChange("column")
+		oldByName := columnsByName(oldColumns.([]interface{}))
+		newByName := columnsByName(newColumns.([]interface{}))
+
+		for _, c := range oldColumns.([]interface{}) {
+			name := c.(map[string]interface{})["name"].(string)
+			if _, ok := newByName[name]; !ok {
+				if _, err := db.Exec("ALTER TABLE " + tableName + " DROP COLUMN " + name); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, c := range newColumns.([]interface{}) {
+			column := c.(map[string]interface{})
+			name := column["name"].(string)
+
+			old, ok := oldByName[name]
+			if !ok {
+				if _, err := db.Exec("ALTER TABLE " + tableName + " ADD COLUMN " + columnDefinition(column)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			//Only widening varchar columns gets this far, other type changes recreate the table
+			if normalizeColumnType(old["type"].(string)) != normalizeColumnType(column["type"].(string)) {
+				if _, err := db.Exec("ALTER TABLE " + tableName + " ALTER COLUMN " + name + " TYPE " + column["type"].(string)); err != nil {
+					return err
+				}
+			}
+			if encoding := column["encoding"].(string); encoding != "" && !strings.EqualFold(encoding, old["encoding"].(string)) {
+				if _, err := db.Exec("ALTER TABLE " + tableName + " ALTER COLUMN " + name + " ENCODE " + encoding); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if d.HasChange("distkey") && d.Get("distkey").(string) != "" {
+		if _, err := db.Exec("ALTER TABLE " + tableName + " ALTER DISTKEY " + d.Get("distkey").(string)); err != nil {
+			return err
+		}
+	} else if d.HasChange("diststyle") && d.Get("diststyle").(string) != "KEY" {
+		if _, err := db.Exec("ALTER TABLE " + tableName + " ALTER DISTSTYLE " + d.Get("diststyle").(string)); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("row_level_security") || d.HasChange("row_level_security_conjunction_type") {
+		if _, err := db.Exec("ALTER TABLE " + tableName + rowLevelSecurityClause(d)); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("sortkey") || d.HasChange("sortkey_style") {
+		sortkey := interfacesToStrings(d.Get("sortkey").([]interface{}))
+
+		var alterSortkeyStatement = "ALTER TABLE " + tableName
+		switch {
+		case d.Get("sortkey_style").(string) == "AUTO":
+			alterSortkeyStatement += " ALTER SORTKEY AUTO"
+		case len(sortkey) > 0:
+			alterSortkeyStatement += " ALTER COMPOUND SORTKEY(" + strings.Join(sortkey, ", ") + ")"
+		default:
+			alterSortkeyStatement += " ALTER SORTKEY NONE"
+		}
+
+		if _, err := db.Exec(alterSortkeyStatement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// https://docs.aws.amazon.com/redshift/latest/dg/r_SVV_RLS_RELATION.html
+func readRedshiftTableRowLevelSecurity(d *schema.ResourceData, q Queryer) error {
+	var (
+		rlsOn           bool
+		conjunctionType sql.NullString
+	)
+
+	err := q.QueryRow(`
+			SELECT r.is_rls_on, trim(r.rls_conjunction_type)
+			FROM svv_rls_relation r
+			JOIN pg_namespace n ON n.nspname = trim(r.relschema)
+			JOIN pg_class c ON c.relnamespace = n.oid AND c.relname = trim(r.relname)
+			WHERE r.datname = current_database() AND c.oid = $1`, d.Id()).Scan(&rlsOn, &conjunctionType)
+
+	switch {
+	case err == sql.ErrNoRows:
+		d.Set("row_level_security", false)
+		return nil
+	case err != nil:
+		return err
+	}
+
+	d.Set("row_level_security", rlsOn)
+	if rlsOn && conjunctionType.Valid && conjunctionType.String != "" {
+		d.Set("row_level_security_conjunction_type", strings.ToUpper(conjunctionType.String))
+	}
+
+	return nil
+}
+
+func rowLevelSecurityClause(d *schema.ResourceData) string {
+	if !d.Get("row_level_security").(bool) {
+		return " ROW LEVEL SECURITY OFF"
+	}
+	return " ROW LEVEL SECURITY ON CONJUNCTION TYPE " + d.Get("row_level_security_conjunction_type").(string)
+}
+
+func resourceRedshiftTableDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	schemaName, _, schemaErr := GetSchemaInfoForSchemaId(client, d.Get("schema_id").(int))
+	if schemaErr != nil {
+		log.Print(schemaErr)
+		return schemaErr
+	}
+
+	dropTableQuery := "DROP TABLE " + schemaName + "." + d.Get("table_name").(string)
+
+	if v, ok := d.GetOk("cascade_on_delete"); ok && v.(bool) {
+		dropTableQuery += " CASCADE "
+	}
+
+	_, err := client.Exec(dropTableQuery)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftTableImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if err := resourceRedshiftTableRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+// Recreates the table for changes that cannot be made with ALTER TABLE
+func resourceRedshiftTableCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+
+	if d.Id() == "" {
+		return nil
+	}
+
+	if d.HasChange("sortkey_style") || d.HasChange("sortkey") {
+		oldStyle, newStyle := d.GetChange("sortkey_style")
+		if oldStyle.(string) == "INTERLEAVED" || newStyle.(string) == "INTERLEAVED" {
+			if err := d.ForceNew("sortkey_style"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.HasChange("column") {
+		oldColumns, newColumns := d.GetChange("column")
+		if !columnChangesCanBeAltered(oldColumns.([]interface{}), newColumns.([]interface{})) {
+			return d.ForceNew("column")
+		}
+	}
+
+	return nil
+}
+
+// Columns can be added at the end, dropped, re-encoded or have their varchar length increased
+func columnChangesCanBeAltered(oldColumns []interface{}, newColumns []interface{}) bool {
+
+	oldByName := columnsByName(oldColumns)
+	newByName := columnsByName(newColumns)
+
+	var kept []string
+	for _, c := range oldColumns {
+		name := c.(map[string]interface{})["name"].(string)
+		if _, ok := newByName[name]; ok {
+			kept = append(kept, name)
+		}
+	}
+
+	//Added columns always go at the end of the table
+	if len(newColumns) < len(kept) {
+		return false
+	}
+	for i, name := range kept {
+		if newColumns[i].(map[string]interface{})["name"].(string) != name {
+			return false
+		}
+	}
+
+	for _, name := range kept {
+		old, new := oldByName[name], newByName[name]
+
+		if old["nullable"].(bool) != new["nullable"].(bool) || old["default"].(string) != new["default"].(string) {
+			return false
+		}
+
+		oldType, newType := normalizeColumnType(old["type"].(string)), normalizeColumnType(new["type"].(string))
+		if oldType == newType {
+			continue
+		}
+		//ALTER COLUMN TYPE can only increase the length of a varchar
+		oldLength, oldIsVarchar := varcharLength(oldType)
+		newLength, newIsVarchar := varcharLength(newType)
+		if !oldIsVarchar || !newIsVarchar || newLength < oldLength {
+			return false
+		}
+	}
+
+	return true
+}
+
+func columnDefinition(column map[string]interface{}) string {
+
+	var definition = column["name"].(string) + " " + column["type"].(string)
+
+	if v, ok := column["default"]; ok && v.(string) != "" {
+		definition += " DEFAULT " + v.(string)
+	}
+	if v, ok := column["encoding"]; ok && v.(string) != "" {
+		definition += " ENCODE " + v.(string)
+	}
+	if !column["nullable"].(bool) {
+		definition += " NOT NULL"
+	}
+
+	return definition
+}
+
+func columnsByName(columns []interface{}) map[string]map[string]interface{} {
+	var byName = make(map[string]map[string]interface{})
+
+	for _, c := range columns {
+		if c == nil {
+			continue
+		}
+		column := c.(map[string]interface{})
+		byName[column["name"].(string)] = column
+	}
+
+	return byName
+}
+
+var columnTypeAliases = map[string]string{
+	"varchar":           "character varying(256)",
+	"nvarchar":          "character varying(256)",
+	"text":              "character varying(256)",
+	"character varying": "character varying(256)",
+	"char":              "character(1)",
+	"nchar":             "character(1)",
+	"bpchar":            "character(256)",
+	"character":         "character(1)",
+	"int":               "integer",
+	"int4":              "integer",
+	"int2":              "smallint",
+	"int8":              "bigint",
+	"float4":            "real",
+	"float":             "double precision",
+	"float8":            "double precision",
+	"bool":              "boolean",
+	"decimal":           "numeric(18,0)",
+	"numeric":           "numeric(18,0)",
+	"timestamp":         "timestamp without time zone",
+	"timestamptz":       "timestamp with time zone",
+	"time":              "time without time zone",
+	"timetz":            "time with time zone",
+}
+
+var columnTypeWithArguments = regexp.MustCompile(`^([a-z ]+?)\s*\((.*)\)$`)
+
+// Normalizes a column type to the way format_type reports it, eg varchar(10) to character varying(10)
+func normalizeColumnType(columnType string) string {
+
+	normalized := strings.Join(strings.Fields(strings.ToLower(columnType)), " ")
+
+	if alias, ok := columnTypeAliases[normalized]; ok {
+		return alias
+	}
+
+	if m := columnTypeWithArguments.FindStringSubmatch(normalized); m != nil {
+		name, arguments := m[1], strings.Replace(m[2], " ", "", -1)
+		switch name {
+		case "varchar", "nvarchar", "character varying":
+			name = "character varying"
+		case "char", "nchar", "bpchar":
+			name = "character"
+		case "decimal":
+			name = "numeric"
+		}
+		return name + "(" + arguments + ")"
+	}
+
+	return normalized
+}
+
+// Returns the length of a normalized varchar type, max being 65535
+func varcharLength(columnType string) (int, bool) {
+	if !strings.HasPrefix(columnType, "character varying(") || !strings.HasSuffix(columnType, ")") {
+		return 0, false
+	}
+	length := strings.TrimSuffix(strings.TrimPrefix(columnType, "character varying("), ")")
+	if length == "max" {
+		return 65535, true
+	}
+	l, err := strconv.Atoi(length)
+	if err != nil {
+		return 0, false
+	}
+	return l, true
+}
+
+func suppressEquivalentColumnTypes(k, old, new string, d *schema.ResourceData) bool {
+	return normalizeColumnType(old) == normalizeColumnType(new)
+}
+
+func suppressCaseDiffs(k, old, new string, d *schema.ResourceData) bool {
+	return strings.EqualFold(old, new)
+}
@@ -0,0 +1,65 @@
+package redshift
+
+import (
+	"testing"
+)
+
+func TestNormalizeColumnType(t *testing.T) {
+	var equivalent = map[string]string{
+		"varchar(10)":    "character varying(10)",
+		"VARCHAR":        "character varying(256)",
+		"text":           "character varying(256)",
+		"char(2)":        "character(2)",
+		"int":            "integer",
+		"int8":           "bigint",
+		"DECIMAL(10, 2)": "numeric(10,2)",
+		"float":          "double precision",
+		"timestamptz":    "timestamp with time zone",
+		"bool":           "boolean",
+	}
+
+	for configured, formatted := range equivalent {
+		if normalizeColumnType(configured) != normalizeColumnType(formatted) {
+			t.Fatalf("expected %s to be equivalent to %s, got %s", configured, formatted, normalizeColumnType(configured))
+		}
+	}
+
+	if normalizeColumnType("varchar(10)") == normalizeColumnType("varchar(20)") {
+		t.Fatalf("expected varchar(10) and varchar(20) to differ")
+	}
+}
+
+func TestColumnChangesCanBeAltered(t *testing.T) {
+	column := func(name string, columnType string) interface{} {
+		return map[string]interface{}{
+			"name":     name,
+			"type":     columnType,
+			"encoding": "",
+			"nullable": true,
+			"default":  "",
+		}
+	}
+
+	old := []interface{}{column("id", "int"), column("name", "varchar(10)")}
+
+	var cases = []struct {
+		description string
+		new         []interface{}
+		expected    bool
+	}{
+		{"add column at the end", []interface{}{column("id", "int"), column("name", "varchar(10)"), column("email", "varchar")}, true},
+		{"add column in the middle", []interface{}{column("id", "int"), column("email", "varchar"), column("name", "varchar(10)")}, false},
+		{"drop column", []interface{}{column("name", "varchar(10)")}, true},
+		{"widen varchar", []interface{}{column("id", "int"), column("name", "varchar(20)")}, true},
+		{"widen varchar to max", []interface{}{column("id", "int"), column("name", "varchar(max)")}, true},
+		{"shrink varchar", []interface{}{column("id", "int"), column("name", "varchar(5)")}, false},
+		{"change type", []interface{}{column("id", "bigint"), column("name", "varchar(10)")}, false},
+		{"reorder columns", []interface{}{column("name", "varchar(10)"), column("id", "int")}, false},
+	}
+
+	for _, c := range cases {
+		if actual := columnChangesCanBeAltered(old, c.new); actual != c.expected {
+			t.Fatalf("%s: expected %v, got %v", c.description, c.expected, actual)
+		}
+	}
+}
@@ -1,13 +1,24 @@
 package redshift
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/lib/pq"
 )
 
 func redshiftUser() *schema.Resource {
@@ -26,19 +37,51 @@ func redshiftUser() *schema.Resource {
 				Type:     schema.TypeString,
 				Required: true,
 			},
-			"password": { //Can we read this back from the db? If not hwo can we tell if its changed? Do we need to use md5hash?
-				Type:      schema.TypeString,
-				Optional:  true,
-				Sensitive: true,
+			"password": { //This can't be read back from the db, verify_password logs in as the user to tell if its changed
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				ValidateFunc:  validatePassword,
+				ConflictsWith: []string{"password_wo"},
+				Description:   "A clear text password, or an md5 or sha256 hash of it so that only the hash is stored in the state",
+			},
+			"password_wo": { //Needs terraform 1.11, it is never stored in the plan or the state
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				WriteOnly:     true,
+				ValidateFunc:  validatePassword,
+				ConflictsWith: []string{"password", "verify_password"},
+				Description:   "Write-only password, only set when the user is created or password_wo_version changes",
+			},
+			"password_wo_version": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				RequiredWith: []string{"password_wo"},
+				Description:  "Change this to set the password to the current value of password_wo",
+			},
+			"hash_password": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"md5", "sha256"}, false),
+				Description:  "Hash a clear text password with md5 or sha256 before it is sent to Redshift",
+			},
+			"verify_password": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Log in as the user when reading it, and reset the password if the login fails. Hashed passwords can't be verified",
 			},
 			"valid_until": {
-				Type:     schema.TypeString,
-				Optional: true,
+				Type:             schema.TypeString,
+				Optional:         true,
+				DiffSuppressFunc: suppressEquivalentValidUntil,
 			},
-			"password_disabled": {
-				Type:     schema.TypeBool,
-				Optional: true,
-				Default:  false,
+			"password_disabled": { //Only compared when set, so that a password set by redshift_user_password is not a diff
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+				Description: "Without password, password_wo or password_disabled the user is created with its password disabled",
 			},
 			"createdb": { //Allows them to create databases
 				Type:     schema.TypeBool,
@@ -60,6 +103,28 @@ func redshiftUser() *schema.Resource {
 				Optional: true,
 				Default:  false,
 			},
+			"session_timeout": { //Seconds a session can be idle or inactive before it is terminated
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntBetween(60, 1728000),
+			},
+			"parameters": { //Session defaults such as search_path, statement_timeout, query_group or timezone
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"reassign_owned_to": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "User that objects owned by the user are reassigned to when it is dropped, defaults to the user of the provider",
+			},
+			"owned_objects_on_delete": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "REASSIGN",
+				ValidateFunc: validation.StringInSlice([]string{"REASSIGN", "FAIL", "DROP"}, false),
+				Description:  "Reassign objects owned by the user when it is dropped, fail with a list of them, or drop them",
+			},
 			"usesysid": {
 				Type:     schema.TypeString,
 				Computed: true,
@@ -98,10 +163,15 @@ func resourceRedshiftUserCreate(d *schema.ResourceData, meta interface{}) error
 
 	if v, ok := d.GetOk("password_disabled"); ok && v.(bool) {
 		createStatement += " DISABLE "
-	} else if v, ok := d.GetOk("password"); ok {
-		createStatement += "'" + v.(string) + "' "
+	} else if password, err := configuredPassword(d, d.Get("username").(string)); err != nil {
+		return err
+	} else if password != "" {
+		createStatement += "'" + password + "' "
+	} else if passwordDisabled, diags := d.GetRawConfigAt(cty.GetAttrPath("password_disabled")); diags.HasError() || !passwordDisabled.IsNull() {
+		return fmt.Errorf("Either password_disabled attribute has to be set to true or password or password_wo attribute has to be provided")
 	} else {
-		return fmt.Errorf("Either password_disabled attribute has to be set to true or password attribute has to be provided")
+		//Without any password the password is left to redshift_user_password
+		createStatement += " DISABLE "
 	}
 
 	if v, ok := d.GetOk("valid_until"); ok {
@@ -131,6 +201,9 @@ func resourceRedshiftUserCreate(d *schema.ResourceData, meta interface{}) error
 	if v, ok := d.GetOk("superuser"); ok && v.(bool) {
 		createStatement += " CREATEUSER "
 	}
+	if v, ok := d.GetOk("session_timeout"); ok {
+		createStatement += " SESSION TIMEOUT " + strconv.Itoa(v.(int))
+	}
 
 	if _, err := tx.Exec(createStatement); err != nil {
 		return fmt.Errorf("Could not create redshift user: %s", err)
@@ -154,6 +227,14 @@ func resourceRedshiftUserCreate(d *schema.ResourceData, meta interface{}) error
 
 	d.SetId(usesysid)
 
+	if err := setUserParameters(tx, d.Get("username").(string), d.Get("parameters").(map[string]interface{})); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("setting user parameters failed; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
 	readErr := readRedshiftUser(d, tx)
 
 	if readErr != nil {
@@ -199,9 +280,53 @@ func resourceRedshiftUserRead(d *schema.ResourceData, meta interface{}) error {
 		return commitErr
 	}
 
+	if d.Get("verify_password").(bool) && !d.Get("password_disabled").(bool) {
+		password := d.Get("password").(string)
+
+		if password != "" && !isMd5PasswordHash(password) && !strings.HasPrefix(password, "sha256|") {
+			verified, verifyErr := verifyPassword(meta.(*Client), d.Get("username").(string), password)
+			switch {
+			case verifyErr != nil:
+				//Don't reset the password when eg the cluster can't be reached
+				log.Printf("Could not verify the password of %s: %v", d.Get("username").(string), verifyErr)
+			case !verified:
+				log.Printf("The password of %s was changed outside of terraform", d.Get("username").(string))
+				d.Set("password", "")
+			}
+		}
+	}
+
 	return nil
 }
 
+// Returns whether the user can log in with the password. Errors other than a failed login, such as the
+// connection limit of the user being reached, are returned as errors rather than as a changed password
+func verifyPassword(c *Client, username string, password string) (bool, error) {
+
+	config := c.config
+	config.user = username
+	config.password = password
+
+	userClient, err := config.Client()
+	if err != nil {
+		return false, err
+	}
+	defer userClient.db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = userClient.db.PingContext(ctx)
+	if pqErr, ok := err.(*pq.Error); ok && (pqErr.Code == "28P01" || pqErr.Code == "28000") {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func readRedshiftUser(d *schema.ResourceData, tx *sql.Tx) error {
 
 	var (
@@ -210,14 +335,17 @@ func readRedshiftUser(d *schema.ResourceData, tx *sql.Tx) error {
 		usesuper     bool
 		valuntil     sql.NullString
 		useconnlimit sql.NullString
+		useconfig    []string
+		passwordSet  bool
 	)
 
-	var readUserQuery = "select usename, usecreatedb, usesuper, valuntil, useconnlimit " +
+	//passwd is null when the password is disabled
+	var readUserQuery = "select usename, usecreatedb, usesuper, valuntil, useconnlimit, useconfig, passwd is not null " +
 		"from pg_user_info where usesysid = $1"
 
 	log.Print("Reading redshift user with query: " + readUserQuery)
 
-	err := tx.QueryRow(readUserQuery, d.Id()).Scan(&usename, &usecreatedb, &usesuper, &valuntil, &useconnlimit)
+	err := tx.QueryRow(readUserQuery, d.Id()).Scan(&usename, &usecreatedb, &usesuper, &valuntil, &useconnlimit, pq.Array(&useconfig), &passwordSet)
 
 	if err != nil {
 		log.Print("Reading user does not exist")
@@ -230,10 +358,11 @@ func readRedshiftUser(d *schema.ResourceData, tx *sql.Tx) error {
 	d.Set("username", usename)
 	d.Set("createdb", usecreatedb)
 	d.Set("superuser", usesuper)
+	d.Set("password_disabled", !passwordSet)
 
 	if valuntil.Valid {
 		log.Print("Valid until " + valuntil.String)
-		d.Set("valid_until", valuntil.String)
+		d.Set("valid_until", normalizeValidUntil(valuntil.String))
 	} else {
 		d.Set("valid_until", nil)
 	}
@@ -245,6 +374,36 @@ func readRedshiftUser(d *schema.ResourceData, tx *sql.Tx) error {
 		d.Set("connection_limit", nil)
 	}
 
+	//Syslog access and session timeouts are only in svv_user_info, where a session timeout of 0 means none is set
+	var (
+		syslogAccess   sql.NullString
+		sessionTimeout sql.NullInt64
+	)
+	err = tx.QueryRow("select trim(syslog_access), session_timeout from svv_user_info where user_id = $1", d.Id()).Scan(&syslogAccess, &sessionTimeout)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+	if syslogAccess.Valid {
+		d.Set("syslog_access", strings.ToUpper(syslogAccess.String))
+	}
+	d.Set("session_timeout", int(sessionTimeout.Int64))
+
+	//useconfig has an entry of the form name=value for every parameter set for the user, with the values
+	//quoted and spaced the way the database stores them, eg "$user", public
+	var configured = d.Get("parameters").(map[string]interface{})
+	var parameters = make(map[string]interface{})
+	for _, config := range useconfig {
+		if parts := strings.SplitN(config, "=", 2); len(parts) == 2 {
+			if v, ok := configured[parts[0]]; ok && normalizeConfigValue(v.(string)) == normalizeConfigValue(parts[1]) {
+				parameters[parts[0]] = v
+			} else {
+				parameters[parts[0]] = parts[1]
+			}
+		}
+	}
+	d.Set("parameters", parameters)
+
 	return nil
 }
 
@@ -260,6 +419,13 @@ func resourceRedshiftUserUpdate(d *schema.ResourceData, meta interface{}) error
 	if d.HasChange("username") {
 
 		oldUsername, newUsername := d.GetChange("username")
+
+		//md5 hashes are salted with the user name, so resetting the same hash would lock the user out.
+		//Passwords hashed by the provider are hashed again with the new user name
+		if isMd5PasswordHash(d.Get("password").(string)) && !d.HasChange("password") && !d.Get("password_disabled").(bool) {
+			return fmt.Errorf("The md5 password hash of %s has to be recomputed with the new user name %s", oldUsername.(string), newUsername.(string))
+		}
+
 		alterUserQuery := "alter user " + oldUsername.(string) + " rename to " + newUsername.(string)
 
 		if _, err := tx.Exec(alterUserQuery); err != nil {
@@ -270,7 +436,7 @@ func resourceRedshiftUserUpdate(d *schema.ResourceData, meta interface{}) error
 		if err := resetPassword(tx, d, newUsername.(string)); err != nil {
 			return err
 		}
-	} else if d.HasChange("password") || d.HasChange("password_disabled") || d.HasChange("valid_until") {
+	} else if d.HasChange("password") || d.HasChange("password_wo_version") || d.HasChange("hash_password") || d.HasChange("password_disabled") || d.HasChange("valid_until") {
 		if err := resetPassword(tx, d, d.Get("username").(string)); err != nil {
 			return err
 		}
@@ -311,6 +477,34 @@ func resourceRedshiftUserUpdate(d *schema.ResourceData, meta interface{}) error
 		}
 	}
 
+	if d.HasChange("session_timeout") {
+		if v, ok := d.GetOk("session_timeout"); ok {
+			if _, err := tx.Exec("alter user " + d.Get("username").(string) + " SESSION TIMEOUT " + strconv.Itoa(v.(int))); err != nil {
+				return err
+			}
+		} else {
+			if _, err := tx.Exec("alter user " + d.Get("username").(string) + " RESET SESSION TIMEOUT"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.HasChange("parameters") {
+		oldParameters, newParameters := d.GetChange("parameters")
+
+		for name := range oldParameters.(map[string]interface{}) {
+			if _, ok := newParameters.(map[string]interface{})[name]; !ok {
+				if _, err := tx.Exec("alter user " + d.Get("username").(string) + " RESET " + name); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := setUserParameters(tx, d.Get("username").(string), newParameters.(map[string]interface{})); err != nil {
+			return err
+		}
+	}
+
 	err := readRedshiftUser(d, tx)
 
 	if err != nil {
@@ -330,6 +524,122 @@ func resourceRedshiftUserUpdate(d *schema.ResourceData, meta interface{}) error
 	return nil
 }
 
+var (
+	md5PasswordHash    = regexp.MustCompile(`^md5[0-9a-f]{32}$`)
+	sha256PasswordHash = regexp.MustCompile(`^sha256\|[0-9a-f]{64}\|.+$`)
+)
+
+// https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_USER.html
+// Redshift takes a password in clear text, as md5 followed by the md5 of the password and user name, or as
+// sha256|<sha256 of the password and salt>|<salt>. Anything else starting with md5 or sha256| is likely a
+// hash that was computed wrongly, which Redshift would otherwise take as the clear text password
+func validatePassword(v interface{}, k string) (ws []string, es []error) {
+	password := v.(string)
+
+	switch {
+	case strings.HasPrefix(password, "md5") && len(password) == 35 && !isMd5PasswordHash(password):
+		es = append(es, fmt.Errorf("%s looks like an md5 hash but is not md5 followed by 32 lower case hex characters", k))
+	case strings.HasPrefix(password, "sha256|") && strings.Count(password, "|") == 2 && !sha256PasswordHash.MatchString(password):
+		es = append(es, fmt.Errorf("%s looks like a sha256 hash but is not sha256|<64 lower case hex characters>|<salt>", k))
+	}
+
+	return
+}
+
+func isMd5PasswordHash(password string) bool {
+	return md5PasswordHash.MatchString(password)
+}
+
+/*
+Returns password_wo, which is only in the configuration, or password, hashed when hash_password is set.
+An empty password means neither is set
+*/
+func configuredPassword(d *schema.ResourceData, username string) (string, error) {
+
+	password := d.Get("password").(string)
+
+	writeOnlyPassword, diags := d.GetRawConfigAt(cty.GetAttrPath("password_wo"))
+	if diags.HasError() {
+		return "", fmt.Errorf("Could not read password_wo from the configuration: %v", diags)
+	}
+	if writeOnlyPassword.Type() == cty.String && writeOnlyPassword.IsKnown() && !writeOnlyPassword.IsNull() {
+		password = writeOnlyPassword.AsString()
+	}
+
+	if password == "" || isMd5PasswordHash(password) || strings.HasPrefix(password, "sha256|") {
+		return password, nil
+	}
+
+	switch d.Get("hash_password").(string) {
+	case "md5":
+		return md5Password(password, username), nil
+	case "sha256":
+		return sha256Password(password)
+	}
+	return password, nil
+}
+
+// md5 followed by the md5 of the password and the user name
+func md5Password(password string, username string) string {
+	sum := md5.Sum([]byte(password + username))
+	return "md5" + hex.EncodeToString(sum[:])
+}
+
+// sha256|<sha256 of the password and the salt>|<salt> with a random salt
+func sha256Password(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("Could not generate a salt: %s", err)
+	}
+	saltHex := hex.EncodeToString(salt)
+
+	sum := sha256.Sum256([]byte(password + saltHex))
+	return "sha256|" + hex.EncodeToString(sum[:]) + "|" + saltHex, nil
+}
+
+var validUntilLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05-07",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// valuntil is read back as a timestamp, while it is usually configured as a date. Both are formatted as a UTC
+// timestamp so they can be compared, anything that doesn't parse such as infinity is returned as is
+func normalizeValidUntil(validUntil string) string {
+	for _, layout := range validUntilLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(validUntil)); err == nil {
+			return t.UTC().Format("2006-01-02 15:04:05")
+		}
+	}
+	return validUntil
+}
+
+func suppressEquivalentValidUntil(k, old, new string, d *schema.ResourceData) bool {
+	return normalizeValidUntil(old) == normalizeValidUntil(new)
+}
+
+// https://docs.aws.amazon.com/redshift/latest/dg/r_ALTER_USER.html
+func setUserParameters(tx *sql.Tx, username string, parameters map[string]interface{}) error {
+
+	for name, value := range parameters {
+		//search_path is a list of schemas, each of which is quoted on its own
+		var setParameterQuery = "alter user " + username + " SET " + name + " TO "
+		if strings.EqualFold(name, "search_path") {
+			setParameterQuery += quoteConfigValue(value.(string))
+		} else {
+			setParameterQuery += pq.QuoteLiteral(value.(string))
+		}
+
+		if _, err := tx.Exec(setParameterQuery); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func resetPassword(tx *sql.Tx, d *schema.ResourceData, username string) error {
 
 	if v, ok := d.GetOk("password_disabled"); ok && v.(bool) {
@@ -342,7 +652,15 @@ func resetPassword(tx *sql.Tx, d *schema.ResourceData, username string) error {
 		return nil
 
 	} else {
-		var resetPasswordQuery = "alter user " + username + " password '" + d.Get("password").(string) + "' "
+		password, err := configuredPassword(d, username)
+		if err != nil {
+			return err
+		}
+		if password == "" {
+			return fmt.Errorf("Either password_disabled attribute has to be set to true or password or password_wo attribute has to be provided")
+		}
+
+		var resetPasswordQuery = "alter user " + username + " password '" + password + "' "
 		if v, ok := d.GetOk("valid_until"); ok {
 			resetPasswordQuery += " VALID UNTIL '" + v.(string) + "'"
 
@@ -356,14 +674,8 @@ func resetPassword(tx *sql.Tx, d *schema.ResourceData, username string) error {
 
 func resourceRedshiftUserDelete(d *schema.ResourceData, meta interface{}) error {
 
-	redshiftClient := meta.(*Client).db
-	redshiftClientConfig := meta.(*Client).config
-
-	tx, txErr := redshiftClient.Begin()
-
-	if txErr != nil {
-		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
-	}
+	client := meta.(*Client)
+	redshiftClient := client.db
 
 	// https://docs.aws.amazon.com/redshift/latest/dg/r_DROP_USER.html
 	// If a user owns an object, first drop the object or change its ownership to another user before dropping
@@ -371,108 +683,62 @@ func resourceRedshiftUserDelete(d *schema.ResourceData, meta interface{}) error
 	// the user. The following example shows dropping an object, changing ownership, and revoking privileges
 	// before dropping the user
 	//
-	// There is no equivalent of Postgres REASSIGN USER unfortunately
+	// There is no equivalent of Postgres REASSIGN USER or DROP OWNED unfortunately
 	//
-	// It is necessary to query and reassign to current user.
+	// It is necessary to query and reassign to another user, which is the current user unless reassign_owned_to is set.
 	// See some discussion her: https://dba.stackexchange.com/questions/143938/drop-user-in-redshift-which-has-privilege-on-some-object
 	//
-	// Derived from https://github.com/awslabs/amazon-redshift-utils/blob/master/src/AdminViews/v_find_dropuser_objs.sql
-	var reassignOwnerGenerator = `SELECT owner.ddl
-		FROM (
-				-- Functions owned by the user
-				SELECT pgu.usesysid,
-				'alter function ' || QUOTE_IDENT(nc.nspname) || '.' ||textin (regprocedureout (pproc.oid::regprocedure)) || ' owner to '
-				FROM pg_proc pproc,pg_user pgu,pg_namespace nc
-				WHERE pproc.pronamespace = nc.oid
-				AND   pproc.proowner = pgu.usesysid
-			UNION ALL
-				-- Databases owned by the user
-				SELECT pgu.usesysid,
-				'alter database ' || QUOTE_IDENT(pgd.datname) || ' owner to '
-				FROM pg_database pgd,
-				     pg_user pgu
-				WHERE pgd.datdba = pgu.usesysid
-			UNION ALL
-				-- Schemas owned by the user
-				SELECT pgu.usesysid,
-				'alter schema '|| QUOTE_IDENT(pgn.nspname) ||' owner to '
-				FROM pg_namespace pgn,
-				     pg_user pgu
-				WHERE pgn.nspowner = pgu.usesysid
-			UNION ALL
-				-- Tables or Views owned by the user
-				SELECT pgu.usesysid,
-				'alter table ' || QUOTE_IDENT(nc.nspname) || '.' || QUOTE_IDENT(pgc.relname) || ' owner to '
-				FROM pg_class pgc,
-				     pg_user pgu,
-				     pg_namespace nc
-				WHERE pgc.relnamespace = nc.oid
-				AND   pgc.relkind IN ('r','v')
-				AND   pgu.usesysid = pgc.relowner
-				AND   nc.nspname NOT ILIKE 'pg\_temp\_%'
-		)
-		OWNER("userid", "ddl")
-		WHERE owner.userid = $1;`
-
-	rows, reassignOwnerStatementErr := tx.Query(reassignOwnerGenerator, d.Id())
-
-	defer rows.Close()
-
-	if reassignOwnerStatementErr != nil {
-		if rollbackErr := tx.Rollback(); rollbackErr != nil {
-			log.Printf("error reassigning owner: rollback failed: %v", rollbackErr)
+	// Users are shared by all databases, but objects and privileges are not, so this is done in every database
+	var newOwner = client.config.user
+	if v, ok := d.GetOk("reassign_owned_to"); ok {
+		var usernames = GetUsersnamesForUsesysid(redshiftClient, []interface{}{v.(int)})
+		if len(usernames) == 0 {
+			return fmt.Errorf("Could not drop user %s: reassign_owned_to user %d does not exist", d.Get("username").(string), v.(int))
 		}
-		log.Print(reassignOwnerStatementErr)
-		return reassignOwnerStatementErr
+		newOwner = usernames[0]
 	}
 
-	var reassignStatements []string
-
-	for rows.Next() {
+	otherDatabases, databasesErr := getOtherLocalDatabases(redshiftClient, client.config.database)
+	if databasesErr != nil {
+		log.Print(databasesErr)
+		return databasesErr
+	}
 
-		var reassignStatement string
+	//Every database is checked before any of them is changed, so that nothing is left half cleaned up
+	if err := checkOwnedObjectsBeforeDrop(client, otherDatabases, d); err != nil {
+		log.Print(err)
+		return err
+	}
 
-		err := rows.Scan(&reassignStatement)
-		if err != nil {
-			//Im not sure how this can happen
-			if rollbackErr := tx.Rollback(); rollbackErr != nil {
-				log.Printf("error running scan for reassigning owner: rollback failed: %v", rollbackErr)
-			}
+	for _, databaseName := range otherDatabases {
+		if err := cleanUpUserInDatabase(client, databaseName, d, newOwner); err != nil {
 			log.Print(err)
-			return err
+			return fmt.Errorf("Could not clean up user %s in database %s: %s", d.Get("username").(string), databaseName, err)
 		}
-		reassignStatements = append(reassignStatements, reassignStatement)
 	}
 
-	for _, statement := range reassignStatements {
-		_, err := tx.Exec(statement + redshiftClientConfig.user)
+	tx, txErr := redshiftClient.Begin()
 
-		if err != nil {
-			//Im not sure how this can happen
-			if rollbackErr := tx.Rollback(); rollbackErr != nil {
-				log.Printf("error reassigning owner: rollback failed: %v", rollbackErr)
-			}
-			log.Print(err)
-			return err
-		}
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
 	}
 
-	//We need to drop all privileges and default privileges
-	rows, schemasError := redshiftClient.Query("select nspname from pg_namespace")
-	defer rows.Close()
-	if schemasError != nil {
-		return schemasError
+	//Databases are shared too, so they are only reassigned or reported once
+	if err := handleOwnedObjects(tx, d, newOwner, true); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reassigning or dropping owned objects: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
 	}
 
-	for rows.Next() {
-		var schemaName string
-		err := rows.Scan(&schemaName)
-		if err != nil {
-			//Im not sure how this can happen
-			return err
+	//We need to drop all privileges and default privileges
+	if err := revokeAllPrivileges(tx, d.Get("username").(string), false); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("revoking privileges failed; unable to rollback: %v", rollbackErr)
 		}
-		redshiftClient.Exec("REVOKE ALL ON ALL TABLES IN SCHEMA " + schemaName + " FROM " + d.Get("username").(string))
-		redshiftClient.Exec("ALTER DEFAULT PRIVILEGES IN SCHEMA " + schemaName + " REVOKE ALL ON TABLES FROM " + d.Get("username").(string) + " CASCADE")
+		log.Print(err)
+		return err
 	}
 
 	_, dropUserErr := tx.Exec("DROP USER " + d.Get("username").(string))
@@ -482,7 +748,7 @@ func resourceRedshiftUserDelete(d *schema.ResourceData, meta interface{}) error
 			log.Printf("drop user failed; unable to rollback: %v", rollbackErr)
 		}
 		log.Print(dropUserErr)
-		return dropUserErr
+		return reportUserDropBlockers(client, append([]string{client.config.database}, otherDatabases...), d, dropUserErr)
 	}
 
 	commitErr := tx.Commit()
@@ -494,6 +760,302 @@ func resourceRedshiftUserDelete(d *schema.ResourceData, meta interface{}) error
 	return nil
 }
 
+// Reassigns, drops or reports the objects owned by the user in the database of the transaction
+func handleOwnedObjects(tx *sql.Tx, d *schema.ResourceData, newOwner string, includeDatabases bool) error {
+
+	ownedObjects, err := getOwnedObjects(tx, d.Id())
+	if err != nil {
+		return err
+	}
+
+	if !includeDatabases {
+		var objects []ownedObject
+		for _, object := range ownedObjects {
+			if object.kind != "database" {
+				objects = append(objects, object)
+			}
+		}
+		ownedObjects = objects
+	}
+
+	var ownedObjectStatements []string
+
+	switch d.Get("owned_objects_on_delete").(string) {
+	case "FAIL":
+		if len(ownedObjects) > 0 {
+			return fmt.Errorf("User %s still owns %d objects: %s", d.Get("username").(string), len(ownedObjects), describeOwnedObjects(ownedObjects))
+		}
+	case "DROP":
+		//Dependent objects are dropped first, databases can't be dropped in a transaction so they are still reassigned
+		sort.SliceStable(ownedObjects, func(i, j int) bool {
+			return ownedObjectDropOrder[ownedObjects[i].kind] < ownedObjectDropOrder[ownedObjects[j].kind]
+		})
+		for _, object := range ownedObjects {
+			if object.kind == "database" {
+				ownedObjectStatements = append(ownedObjectStatements, "alter database "+object.name+" owner to "+newOwner)
+			} else {
+				ownedObjectStatements = append(ownedObjectStatements, "drop "+object.kind+" "+object.name)
+			}
+		}
+	default:
+		for _, object := range ownedObjects {
+			//Views and materialized views are altered with alter table
+			var kind = object.kind
+			if kind == "view" || kind == "materialized view" {
+				kind = "table"
+			}
+			ownedObjectStatements = append(ownedObjectStatements, "alter "+kind+" "+object.name+" owner to "+newOwner)
+		}
+	}
+
+	//Objects are dropped without CASCADE so that objects of other users that depend on them, such as views, are never
+	//dropped along with them. Dropping stops at the first object something else still depends on
+	for _, statement := range ownedObjectStatements {
+		log.Print("Owned object statement: " + statement)
+
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("Could not %s owned by user %s: %s", statement, d.Get("username").(string), err)
+		}
+	}
+
+	return nil
+}
+
+// With owned_objects_on_delete = FAIL, returns the objects the user owns in the connected database and every other one
+func checkOwnedObjectsBeforeDrop(c *Client, otherDatabases []string, d *schema.ResourceData) error {
+
+	if d.Get("owned_objects_on_delete").(string) != "FAIL" {
+		return nil
+	}
+
+	var blockers []string
+
+	ownedObjects, err := getOwnedObjects(c.db, d.Id())
+	if err != nil {
+		return err
+	}
+	if len(ownedObjects) > 0 {
+		blockers = append(blockers, "in database "+c.config.database+" "+describeOwnedObjects(ownedObjects))
+	}
+
+	for _, databaseName := range otherDatabases {
+		databaseClient, err := clientForDatabase(c, databaseName)
+		if err != nil {
+			return fmt.Errorf("Could not connect to database %s to find the objects owned by user %s: %s", databaseName, d.Get("username").(string), err)
+		}
+
+		ownedObjects, err := getOwnedObjects(databaseClient.db, d.Id())
+		databaseClient.db.Close()
+		if err != nil {
+			return err
+		}
+
+		//Databases are shared, so they were already found in the connected database
+		var objects []ownedObject
+		for _, object := range ownedObjects {
+			if object.kind != "database" {
+				objects = append(objects, object)
+			}
+		}
+		if len(objects) > 0 {
+			blockers = append(blockers, "in database "+databaseName+" "+describeOwnedObjects(objects))
+		}
+	}
+
+	if len(blockers) > 0 {
+		return fmt.Errorf("User %s still owns objects: %s", d.Get("username").(string), strings.Join(blockers, "; "))
+	}
+	return nil
+}
+
+func cleanUpUserInDatabase(c *Client, databaseName string, d *schema.ResourceData, newOwner string) error {
+
+	databaseClient, err := clientForDatabase(c, databaseName)
+	if err != nil {
+		return err
+	}
+	defer databaseClient.db.Close()
+
+	tx, txErr := databaseClient.db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	if err := handleOwnedObjects(tx, d, newOwner, false); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reassigning or dropping owned objects: rollback failed: %v", rollbackErr)
+		}
+		return err
+	}
+
+	if err := revokeAllPrivileges(tx, d.Get("username").(string), false); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("revoking privileges failed; unable to rollback: %v", rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Returns the databases other than the connected one that objects can be created in, which excludes databases
+// created from datashares or integrations
+func getOtherLocalDatabases(q Queryer, connectedDatabase string) ([]string, error) {
+
+	rows, err := q.Query(`
+			SELECT trim(database_name) FROM svv_redshift_databases
+			WHERE database_type = 'local' AND trim(database_name) NOT IN ($1, 'template0', 'template1', 'padb_harvest')
+			ORDER BY database_name`, connectedDatabase)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var databases []string
+	for rows.Next() {
+		var databaseName string
+		if err := rows.Scan(&databaseName); err != nil {
+			return nil, err
+		}
+		databases = append(databases, databaseName)
+	}
+
+	return databases, rows.Err()
+}
+
+// Returns the error of DROP USER along with the objects the user still owns and the privileges it still has in every database
+func reportUserDropBlockers(c *Client, databases []string, d *schema.ResourceData, dropUserErr error) error {
+
+	var (
+		username = d.Get("username").(string)
+		blockers []string
+	)
+
+	for _, databaseName := range databases {
+		databaseClient, err := clientForDatabase(c, databaseName)
+		if err != nil {
+			log.Printf("Could not connect to database %s to find what blocks dropping the user: %v", databaseName, err)
+			continue
+		}
+
+		ownedObjects, ownedErr := getOwnedObjects(databaseClient.db, d.Id())
+		privileges, privilegesErr := getGrantedPrivileges(databaseClient.db, username, false)
+		datashareStatements, datashareErr := getDatashareRevokeStatements(databaseClient.db, username, "user", username)
+		databaseClient.db.Close()
+
+		if ownedErr != nil {
+			log.Printf("Could not find objects owned by the user in database %s: %v", databaseName, ownedErr)
+		} else if len(ownedObjects) > 0 {
+			blockers = append(blockers, "in database "+databaseName+" it owns "+describeOwnedObjects(ownedObjects))
+		}
+
+		if privilegesErr != nil {
+			log.Printf("Could not find privileges of the user in database %s: %v", databaseName, privilegesErr)
+		} else if len(privileges) > 0 {
+			blockers = append(blockers, "in database "+databaseName+" it has privileges on "+describeGrantedPrivileges(privileges))
+		}
+
+		if datashareErr != nil {
+			log.Printf("Could not find datashare privileges of the user in database %s: %v", databaseName, datashareErr)
+		} else if len(datashareStatements) > 0 {
+			blockers = append(blockers, "in database "+databaseName+" it still needs "+strings.Join(datashareStatements, ", "))
+		}
+	}
+
+	if len(blockers) == 0 {
+		return fmt.Errorf("Could not drop user %s: %s", username, dropUserErr)
+	}
+	return fmt.Errorf("Could not drop user %s: %s; %s", username, dropUserErr, strings.Join(blockers, "; "))
+}
+
+func describeOwnedObjects(ownedObjects []ownedObject) string {
+	var descriptions []string
+	for _, object := range ownedObjects {
+		descriptions = append(descriptions, object.kind+" "+object.name)
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+type ownedObject struct {
+	kind string
+	name string
+}
+
+var ownedObjectDropOrder = map[string]int{
+	"materialized view": 0,
+	"view":              1,
+	"table":             2,
+	"procedure":         3,
+	"function":          4,
+	"schema":            5,
+	"database":          6,
+}
+
+// Returns the kind and quoted name of the objects owned by a user, functions and procedures include their argument types
+// Derived from https://github.com/awslabs/amazon-redshift-utils/blob/master/src/AdminViews/v_find_dropuser_objs.sql
+func getOwnedObjects(q Queryer, userId string) ([]ownedObject, error) {
+
+	var ownedObjectsQuery = `SELECT owner.kind, owner.name
+		FROM (
+				-- Functions and procedures owned by the user
+				SELECT pproc.proowner,
+				CASE WHEN pproc.prokind = 'p' THEN 'procedure' ELSE 'function' END,
+				QUOTE_IDENT(nc.nspname) || '.' || QUOTE_IDENT(pproc.proname) || '(' || oidvectortypes(pproc.proargtypes) || ')'
+				FROM pg_proc_info pproc,
+				     pg_namespace nc
+				WHERE pproc.pronamespace = nc.oid
+				AND   pproc.prokind IN ('f', 'p')
+			UNION ALL
+				-- Databases owned by the user
+				SELECT pgd.datdba,
+				'database',
+				QUOTE_IDENT(pgd.datname)
+				FROM pg_database pgd
+			UNION ALL
+				-- Schemas owned by the user
+				SELECT pgn.nspowner,
+				'schema',
+				QUOTE_IDENT(pgn.nspname)
+				FROM pg_namespace pgn
+			UNION ALL
+				-- Tables, views or materialized views owned by the user. Materialized views are stored as a view
+				-- and a table called mv_tbl__<view name>__0, which changes owner along with the view
+				SELECT pgc.relowner,
+				CASE
+					WHEN pgc.relkind = 'r' THEN 'table'
+					WHEN EXISTS (SELECT 1 FROM pg_class mv WHERE mv.relnamespace = pgc.relnamespace AND mv.relname = 'mv_tbl__' || pgc.relname || '__0') THEN 'materialized view'
+					ELSE 'view'
+				END,
+				QUOTE_IDENT(nc.nspname) || '.' || QUOTE_IDENT(pgc.relname)
+				FROM pg_class pgc,
+				     pg_namespace nc
+				WHERE pgc.relnamespace = nc.oid
+				AND   pgc.relkind IN ('r','v')
+				AND   pgc.relname NOT LIKE 'mv\_tbl\_\_%'
+				AND   nc.nspname NOT ILIKE 'pg\_temp\_%'
+		)
+		OWNER("userid", "kind", "name")
+		WHERE owner.userid = $1;`
+
+	rows, err := q.Query(ownedObjectsQuery, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ownedObjects []ownedObject
+
+	for rows.Next() {
+		var object ownedObject
+		if err := rows.Scan(&object.kind, &object.name); err != nil {
+			return nil, err
+		}
+		ownedObjects = append(ownedObjects, object)
+	}
+
+	return ownedObjects, rows.Err()
+}
+
 func resourceRedshiftUserImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
 	if err := resourceRedshiftUserRead(d, meta); err != nil {
 		return nil, err
@@ -0,0 +1,262 @@
+package redshift
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+/*
+Generates a password for a user and stores it encrypted with a PGP key, following
+https://github.com/terraform-providers/terraform-provider-aws/blob/master/aws/resource_aws_iam_user_login_profile.go
+The clear text password is never stored in the state. Changing the keepers generates a new password.
+*/
+func redshiftUserPassword() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftUserPasswordCreate,
+		Read:   resourceRedshiftUserPasswordRead,
+		Delete: resourceRedshiftUserPasswordDelete,
+		Exists: resourceRedshiftUserPasswordExists,
+
+		Schema: map[string]*schema.Schema{
+			"user_id": {
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			"pgp_key": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Either a base-64 encoded PGP public key, or a keybase username in the form keybase:username",
+			},
+			"password_length": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      20,
+				ValidateFunc: validation.IntBetween(8, 64),
+			},
+			"keepers": { //Arbitrary values that generate a new password when changed
+				Type:     schema.TypeMap,
+				Optional: true,
+				ForceNew: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"encrypted_password": { //Decrypt with base64 --decode | gpg --decrypt
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"key_fingerprint": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceRedshiftUserPasswordExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var name string
+
+	err := client.QueryRow("SELECT usename FROM pg_user_info WHERE usesysid = $1", d.Get("user_id").(int)).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftUserPasswordCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	entity, err := retrievePgpKey(d.Get("pgp_key").(string))
+	if err != nil {
+		return err
+	}
+
+	password, err := generatePassword(d.Get("password_length").(int))
+	if err != nil {
+		return fmt.Errorf("Could not generate password: %s", err)
+	}
+
+	encryptedPassword, err := encryptWithPgpKey(entity, password)
+	if err != nil {
+		return fmt.Errorf("Could not encrypt password: %s", err)
+	}
+
+	var usernames = GetUsersnamesForUsesysid(redshiftClient, []interface{}{d.Get("user_id").(int)})
+	if len(usernames) == 0 {
+		return fmt.Errorf("User %d does not exist", d.Get("user_id").(int))
+	}
+
+	//The statement is not logged as it contains the password
+	if _, err := redshiftClient.Exec("alter user " + usernames[0] + " password '" + password + "'"); err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.SetId(strconv.Itoa(d.Get("user_id").(int)))
+	d.Set("encrypted_password", encryptedPassword)
+	d.Set("key_fingerprint", hex.EncodeToString(entity.PrimaryKey.Fingerprint[:]))
+
+	return nil
+}
+
+/*
+Nothing is read: Redshift doesn't return passwords, not even their hash as pg_shadow is restricted,
+so there is nothing to compare with the state. Exists removes the resource when the user is dropped,
+and every other attribute is ForceNew and only changes through the configuration.
+*/
+func resourceRedshiftUserPasswordRead(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+/*
+Nothing is changed: Redshift has no way to unset a password, only to disable it, which would lock out
+whoever was given the password as soon as the resource is replaced or removed. Set password_disabled
+on the redshift_user to stop the user logging in with it.
+*/
+func resourceRedshiftUserPasswordDelete(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+// https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_USER.html
+// Passwords need an upper case letter, a lower case letter and a number, and can't contain ' " \ / @ or spaces
+const (
+	passwordLowerCase = "abcdefghijklmnopqrstuvwxyz"
+	passwordUpperCase = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordNumbers   = "0123456789"
+	passwordSymbols   = "!#$%&()*+,-.:;<=>?[]^_{|}~"
+)
+
+func generatePassword(length int) (string, error) {
+
+	var (
+		characters = passwordLowerCase + passwordUpperCase + passwordNumbers + passwordSymbols
+		password   = make([]byte, length)
+	)
+
+	//The first characters meet the requirements, and are shuffled into the rest of the password below
+	for i, set := range []string{passwordLowerCase, passwordUpperCase, passwordNumbers} {
+		c, err := randomCharacter(set)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	for i := 3; i < length; i++ {
+		c, err := randomCharacter(characters)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	for i := length - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+func randomCharacter(characters string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(characters))))
+	if err != nil {
+		return 0, err
+	}
+	return characters[i.Int64()], nil
+}
+
+// So that an unreachable keybase fails the apply rather than hanging it
+var keybaseClient = &http.Client{Timeout: 30 * time.Second}
+
+// Returns the public key from a base-64 encoded key, or from keybase for keybase:username
+func retrievePgpKey(pgpKey string) (*openpgp.Entity, error) {
+
+	if strings.HasPrefix(pgpKey, "keybase:") {
+		username := strings.TrimPrefix(pgpKey, "keybase:")
+
+		resp, err := keybaseClient.Get("https://keybase.io/" + username + "/pgp_keys.asc")
+		if err != nil {
+			return nil, fmt.Errorf("Could not get the PGP key of %s from keybase: %s", username, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Could not get the PGP key of %s from keybase: %s", username, resp.Status)
+		}
+
+		block, err := armor.Decode(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Could not decode the PGP key of %s from keybase: %s", username, err)
+		}
+
+		entity, err := openpgp.ReadEntity(packet.NewReader(block.Body))
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the PGP key of %s from keybase: %s", username, err)
+		}
+		return entity, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(pgpKey))
+	if err != nil {
+		return nil, fmt.Errorf("pgp_key is neither keybase:username nor base-64 encoded: %s", err)
+	}
+
+	entity, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(key)))
+	if err != nil {
+		return nil, fmt.Errorf("Could not read pgp_key: %s", err)
+	}
+	return entity, nil
+}
+
+// Returns the base-64 encoded PGP message
+func encryptWithPgpKey(entity *openpgp.Entity, value string) (string, error) {
+
+	var buffer bytes.Buffer
+
+	writer, err := openpgp.Encrypt(&buffer, []*openpgp.Entity{entity}, nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := writer.Write([]byte(value)); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	encrypted, err := ioutil.ReadAll(&buffer)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
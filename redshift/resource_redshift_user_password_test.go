@@ -0,0 +1,61 @@
+package redshift
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	for _, length := range []int{8, 20, 64} {
+		password, err := generatePassword(length)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(password) != length {
+			t.Fatalf("expected a password of %d characters, got %d", length, len(password))
+		}
+		if !strings.ContainsAny(password, passwordLowerCase) || !strings.ContainsAny(password, passwordUpperCase) || !strings.ContainsAny(password, passwordNumbers) {
+			t.Fatalf("expected %s to contain an upper case letter, a lower case letter and a number", password)
+		}
+		if strings.ContainsAny(password, "'\"\\/@ ") {
+			t.Fatalf("expected %s not to contain ' \" \\ / @ or spaces", password)
+		}
+	}
+}
+
+func TestEncryptWithPgpKey(t *testing.T) {
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := encryptWithPgpKey(entity, "Testpass123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	details, err := openpgp.ReadMessage(bytes.NewReader(message), openpgp.EntityList{entity}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := ioutil.ReadAll(details.UnverifiedBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(decrypted) != "Testpass123" {
+		t.Fatalf("expected Testpass123, got %s", decrypted)
+	}
+}
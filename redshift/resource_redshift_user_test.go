@@ -0,0 +1,73 @@
+package redshift
+
+import (
+	"testing"
+)
+
+func TestNormalizeValidUntil(t *testing.T) {
+	var equivalent = map[string]string{
+		"2018-10-30":                "2018-10-30T00:00:00Z",
+		"2018-10-30 00:00:00":       "2018-10-30 00:00:00+00",
+		"2018-10-30 12:30:00":       "2018-10-30T12:30:00Z",
+		"2018-10-30T14:30:00+02:00": "2018-10-30 12:30:00",
+	}
+
+	for configured, read := range equivalent {
+		if normalizeValidUntil(configured) != normalizeValidUntil(read) {
+			t.Fatalf("expected %s to be equivalent to %s, got %s and %s", configured, read, normalizeValidUntil(configured), normalizeValidUntil(read))
+		}
+	}
+
+	if normalizeValidUntil("infinity") != "infinity" {
+		t.Fatalf("expected infinity to be kept, got %s", normalizeValidUntil("infinity"))
+	}
+
+	if normalizeValidUntil("2018-10-30") == normalizeValidUntil("2018-10-31") {
+		t.Fatalf("expected 2018-10-30 and 2018-10-31 to differ")
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	var valid = []string{
+		"Testpass123",
+		"md5153c434b4b77c89e6b94f12c5393af5b",
+		"sha256|Testpass123",
+		"sha256|8bb6118f8fd6935ad0876a3be34a717d32708ffd2e4e2b5f2f8b1fc1d7e8e1f0|salt",
+	}
+	var invalid = []string{
+		"md5153C434B4B77C89E6B94F12C5393AF5B",
+		"md5153c434b4b77c89e6b94f12c5393afzz",
+		"sha256|8bb6118f|salt",
+	}
+
+	for _, password := range valid {
+		if _, es := validatePassword(password, "password"); len(es) > 0 {
+			t.Fatalf("expected %s to be valid, got %v", password, es)
+		}
+	}
+
+	for _, password := range invalid {
+		if _, es := validatePassword(password, "password"); len(es) == 0 {
+			t.Fatalf("expected %s to be invalid", password)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	if hash := md5Password("Testpass123", "testuser"); hash != "md53142e72b52e279c27cfba926db922164" {
+		t.Fatalf("expected the md5 of Testpass123testuser, got %s", hash)
+	}
+
+	hash, err := sha256Password("Testpass123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, errs := validatePassword(hash, "password"); len(errs) > 0 || !sha256PasswordHash.MatchString(hash) {
+		t.Fatalf("expected a valid sha256 hash, got %s", hash)
+	}
+
+	other, _ := sha256Password("Testpass123")
+	if hash == other {
+		t.Fatalf("expected hashes of the same password to have different salts")
+	}
+}
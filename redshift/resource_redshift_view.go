@@ -0,0 +1,383 @@
+package redshift
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+//https://docs.aws.amazon.com/redshift/latest/dg/r_CREATE_VIEW.html
+//https://docs.aws.amazon.com/redshift/latest/dg/r_DROP_VIEW.html
+
+/*
+The id is the oid of the view in pg_class, so that the view can be renamed in place like schemas.
+
+Redshift rewrites the definition of a view, so the definition read back from pg_views is not
+the same as the configured one. The normalized definition read back is kept in database_definition,
+and the view is only considered to have drifted when that changes.
+*/
+func redshiftView() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRedshiftViewCreate,
+		Read:   resourceRedshiftViewRead,
+		Update: resourceRedshiftViewUpdate,
+		Delete: resourceRedshiftViewDelete,
+		Exists: resourceRedshiftViewExists,
+		Importer: &schema.ResourceImporter{
+			State: resourceRedshiftViewImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"view_name": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"schema_id": { //Views cannot be moved to another schema
+				Type:     schema.TypeInt,
+				Required: true,
+				ForceNew: true,
+			},
+			"definition": {
+				Type:             schema.TypeString,
+				Required:         true,
+				DiffSuppressFunc: suppressEquivalentSqlDiffs,
+				Description:      "The select query of the view",
+			},
+			"with_no_schema_binding": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    true,
+				Description: "Creates a late binding view, which is not bound to the underlying tables",
+			},
+			"owner": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+				Description: "Defaults to user specified in provider",
+			},
+			"cascade_on_delete": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Drop objects that depend on the view, such as other views",
+			},
+			"database_definition": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Normalized definition of the view as read back from pg_views",
+			},
+		},
+	}
+}
+
+func resourceRedshiftViewExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
+	// Exists - This is called to verify a resource still exists. It is called prior to Read,
+	// and lowers the burden of Read to be able to assume the resource exists.
+	client := meta.(*Client).db
+
+	var name string
+
+	err := client.QueryRow("SELECT relname FROM pg_class WHERE relkind = 'v' AND oid = $1", d.Id()).Scan(&name)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceRedshiftViewCreate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	schemaName, _, schemaErr := GetSchemaInfoForSchemaId(tx, d.Get("schema_id").(int))
+	if schemaErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting schema info: rollback failed: %v", rollbackErr)
+		}
+		log.Print(schemaErr)
+		return schemaErr
+	}
+
+	viewName := schemaName + "." + d.Get("view_name").(string)
+
+	if _, err := tx.Exec(createViewStatement(d, viewName)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error creating view; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not create redshift view: %s", err)
+	}
+
+	if v, ok := d.GetOk("owner"); ok {
+		var usernames = GetUsersnamesForUsesysid(tx, []interface{}{v.(int)})
+		if _, err := tx.Exec("ALTER TABLE " + viewName + " OWNER TO " + usernames[0]); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Printf("error setting view owner; unable to rollback: %v", rollbackErr)
+			}
+			log.Print(err)
+			return err
+		}
+	}
+
+	oid, oidErr := getRelationOid(tx, d.Get("schema_id").(int), d.Get("view_name").(string), "v")
+	if oidErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting view oid; unable to rollback: %v", rollbackErr)
+		}
+		return fmt.Errorf("Could not get redshift view id: %s", oidErr)
+	}
+
+	log.Print("Created view with oid: " + oid)
+
+	d.SetId(oid)
+
+	readErr := readRedshiftView(d, tx, false)
+
+	if readErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading view; unable to rollback: %v", rollbackErr)
+		}
+		log.Print(readErr)
+		return readErr
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func resourceRedshiftViewRead(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	err := readRedshiftView(d, tx, true)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading view: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+// When detectDrift is false, the definition read back is taken as the one that was just applied
+func readRedshiftView(d *schema.ResourceData, tx *sql.Tx, detectDrift bool) error {
+	var (
+		viewName   string
+		schemaId   int
+		owner      int
+		definition string
+	)
+
+	err := tx.QueryRow(`
+			SELECT trim(c.relname), c.relnamespace, c.relowner, v.definition
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			JOIN pg_views v ON v.schemaname = n.nspname AND v.viewname = c.relname
+			WHERE c.relkind = 'v' AND c.oid = $1`, d.Id()).Scan(&viewName, &schemaId, &owner, &definition)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	d.Set("view_name", viewName)
+	d.Set("schema_id", schemaId)
+	d.Set("owner", owner)
+
+	//The definition of late binding views keeps the WITH NO SCHEMA BINDING clause
+	lateBinding := strings.Contains(strings.ToLower(normalizeSqlDefinition(definition)), "with no schema binding")
+	d.Set("with_no_schema_binding", lateBinding)
+
+	setDatabaseDefinition(d, definition, detectDrift)
+
+	return nil
+}
+
+func resourceRedshiftViewUpdate(d *schema.ResourceData, meta interface{}) error {
+
+	redshiftClient := meta.(*Client).db
+	tx, txErr := redshiftClient.Begin()
+	if txErr != nil {
+		return fmt.Errorf("Could not begin redshift transaction: %s", txErr)
+	}
+
+	schemaName, _, schemaErr := GetSchemaInfoForSchemaId(tx, d.Get("schema_id").(int))
+	if schemaErr != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error getting schema info: rollback failed: %v", rollbackErr)
+		}
+		log.Print(schemaErr)
+		return schemaErr
+	}
+
+	if err := updateRedshiftView(tx, d, schemaName); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error updating view: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	err := readRedshiftView(d, tx, false)
+
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("error reading view: rollback failed: %v", rollbackErr)
+		}
+		log.Print(err)
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		log.Print("Error committing transaction: ", commitErr)
+		return commitErr
+	}
+
+	return nil
+}
+
+func updateRedshiftView(tx *sql.Tx, d *schema.ResourceData, schemaName string) error {
+
+	//Redshift has no ALTER VIEW, views are renamed and reassigned with ALTER TABLE
+	if d.HasChange("view_name") {
+		oldName, newName := d.GetChange("view_name")
+		if _, err := tx.Exec("ALTER TABLE " + schemaName + "." + oldName.(string) + " RENAME TO " + newName.(string)); err != nil {
+			return err
+		}
+	}
+
+	viewName := schemaName + "." + d.Get("view_name").(string)
+
+	if d.HasChange("definition") {
+		if _, err := tx.Exec(createViewStatement(d, viewName)); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("owner") {
+		var usernames = GetUsersnamesForUsesysid(tx, []interface{}{d.Get("owner").(int)})
+		if _, err := tx.Exec("ALTER TABLE " + viewName + " OWNER TO " + usernames[0]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createViewStatement(d *schema.ResourceData, viewName string) string {
+
+	var createStatement = "CREATE OR REPLACE VIEW " + viewName + " AS " + strings.TrimRight(strings.TrimSpace(d.Get("definition").(string)), ";")
+
+	if d.Get("with_no_schema_binding").(bool) {
+		createStatement += " WITH NO SCHEMA BINDING"
+	}
+
+	log.Print("Create view statement: " + createStatement)
+
+	return createStatement
+}
+
+func resourceRedshiftViewDelete(d *schema.ResourceData, meta interface{}) error {
+
+	client := meta.(*Client).db
+
+	schemaName, _, schemaErr := GetSchemaInfoForSchemaId(client, d.Get("schema_id").(int))
+	if schemaErr != nil {
+		log.Print(schemaErr)
+		return schemaErr
+	}
+
+	dropViewQuery := "DROP VIEW " + schemaName + "." + d.Get("view_name").(string)
+
+	if v, ok := d.GetOk("cascade_on_delete"); ok && v.(bool) {
+		dropViewQuery += " CASCADE "
+	}
+
+	_, err := client.Exec(dropViewQuery)
+
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+
+	return nil
+}
+
+func resourceRedshiftViewImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	if err := resourceRedshiftViewRead(d, meta); err != nil {
+		return nil, err
+	}
+	return []*schema.ResourceData{d}, nil
+}
+
+func getRelationOid(q Queryer, schemaId int, relationName string, relkind string) (string, error) {
+
+	var oid int
+
+	err := q.QueryRow("SELECT oid FROM pg_class WHERE relnamespace = $1 AND relname = $2 AND relkind = $3", schemaId, relationName, relkind).Scan(&oid)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(oid), nil
+}
+
+// The configured definition is only replaced with the one read from the database, which shows up as
+// a diff, when the database definition has changed since it was last applied or read
+func setDatabaseDefinition(d *schema.ResourceData, definition string, detectDrift bool) {
+
+	normalized := normalizeSqlDefinition(definition)
+
+	previous := d.Get("database_definition").(string)
+
+	if detectDrift && previous != "" && previous != normalized {
+		log.Printf("Definition of %s has changed outside of terraform", d.Id())
+		d.Set("definition", definition)
+	}
+
+	//On import there is no configured definition yet
+	if d.Get("definition").(string) == "" {
+		d.Set("definition", definition)
+	}
+
+	d.Set("database_definition", normalized)
+}
+
+// Collapses whitespace and drops trailing semicolons, so that formatting differences are not a diff
+func normalizeSqlDefinition(definition string) string {
+	return strings.TrimRight(strings.Join(strings.Fields(definition), " "), "; ")
+}
+
+func suppressEquivalentSqlDiffs(k, old, new string, d *schema.ResourceData) bool {
+	return normalizeSqlDefinition(old) == normalizeSqlDefinition(new)
+}